@@ -0,0 +1,302 @@
+package patch
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// ChallengeAuth is a Doer that responds to authentication challenges.
+// If a request fails with 401 Unauthorized and a WWW-Authenticate
+// challenge for the Digest or Basic scheme, the request is sent once
+// more with an Authorization header computed from the credentials.
+// Request bodies are buffered in memory so that they can be resent.
+type ChallengeAuth struct {
+	// Next is the Doer that requests are passed to
+	Next Doer
+
+	Username string
+	Password string
+}
+
+// NewChallengeAuth returns a ChallengeAuth that wraps next
+func NewChallengeAuth(next Doer, username, password string) *ChallengeAuth {
+	return &ChallengeAuth{
+		Next:     next,
+		Username: username,
+		Password: password,
+	}
+}
+
+// Do sends the request and, if challenged, sends it again with credentials
+func (a *ChallengeAuth) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		body = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	rsp, err := a.Next.Do(req)
+	if err != nil || rsp.StatusCode != http.StatusUnauthorized {
+		return rsp, err
+	}
+
+	auth, err := a.authorization(req, body, parseChallenges(rsp.Header.Values("WWW-Authenticate")))
+	if err != nil {
+		// The response is not returned, so close its body
+		drainBody(rsp)
+		return nil, err
+	} else if auth == "" {
+		// None of the challenges are supported
+		return rsp, nil
+	}
+
+	drainBody(rsp)
+
+	retry := req.Clone(req.Context())
+	if body != nil {
+		retry.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	retry.Header.Set("Authorization", auth)
+
+	return a.Next.Do(retry)
+}
+
+// drainBody discards the rest of the response body and closes
+// it, so that the connection can be reused
+func drainBody(rsp *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(rsp.Body, maxDrainBytes))
+	_ = rsp.Body.Close()
+}
+
+// authorization returns the Authorization header for the strongest
+// supported challenge, or an empty string if none are supported
+func (a *ChallengeAuth) authorization(req *http.Request, body []byte, challenges []challenge) (string, error) {
+	for _, c := range challenges {
+		if c.scheme == "digest" {
+			return a.digest(req, body, c)
+		}
+	}
+
+	for _, c := range challenges {
+		if c.scheme == "basic" {
+			r := &http.Request{Header: http.Header{}}
+			r.SetBasicAuth(a.Username, a.Password)
+			return r.Header.Get("Authorization"), nil
+		}
+	}
+
+	return "", nil
+}
+
+// digest computes the Authorization header for a Digest challenge
+// as described in RFC 7616, supporting the MD5 and SHA-256 algorithms
+func (a *ChallengeAuth) digest(req *http.Request, body []byte, c challenge) (string, error) {
+	algorithm := c.params["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+
+	var qop string
+	for _, q := range strings.Split(c.params["qop"], ",") {
+		switch q = strings.TrimSpace(q); q {
+		case "auth":
+			qop = q
+		case "auth-int":
+			if qop == "" {
+				qop = q
+			}
+		}
+	}
+
+	cnonce, err := newCnonce()
+	if err != nil {
+		return "", err
+	}
+
+	d := digestParams{
+		newHash:   newHash,
+		algorithm: algorithm,
+		username:  a.Username,
+		password:  a.Password,
+		realm:     c.params["realm"],
+		nonce:     c.params["nonce"],
+		method:    req.Method,
+		uri:       req.URL.RequestURI(),
+		qop:       qop,
+		nc:        "00000001",
+		cnonce:    cnonce,
+		body:      body,
+	}
+
+	fields := []string{
+		fmt.Sprintf("username=%q", d.username),
+		fmt.Sprintf("realm=%q", d.realm),
+		fmt.Sprintf("nonce=%q", d.nonce),
+		fmt.Sprintf("uri=%q", d.uri),
+		"algorithm=" + algorithm,
+		fmt.Sprintf("response=%q", d.response()),
+	}
+
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+d.nc, fmt.Sprintf("cnonce=%q", d.cnonce))
+	}
+
+	if opaque, ok := c.params["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf("opaque=%q", opaque))
+	}
+
+	return "Digest " + strings.Join(fields, ", "), nil
+}
+
+type digestParams struct {
+	newHash   func() hash.Hash
+	algorithm string
+	username  string
+	password  string
+	realm     string
+	nonce     string
+	method    string
+	uri       string
+	qop       string
+	nc        string
+	cnonce    string
+	body      []byte
+}
+
+// response computes the digest response value
+func (d *digestParams) response() string {
+	h := func(s string) string {
+		hh := d.newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	ha1 := h(d.username + ":" + d.realm + ":" + d.password)
+	if strings.HasSuffix(strings.ToUpper(d.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + d.nonce + ":" + d.cnonce)
+	}
+
+	ha2 := h(d.method + ":" + d.uri)
+	if d.qop == "auth-int" {
+		ha2 = h(d.method + ":" + d.uri + ":" + h(string(d.body)))
+	}
+
+	if d.qop == "" {
+		return h(ha1 + ":" + d.nonce + ":" + ha2)
+	}
+
+	return h(ha1 + ":" + d.nonce + ":" + d.nc + ":" + d.cnonce + ":" + d.qop + ":" + ha2)
+}
+
+// newCnonce returns a random client nonce
+var newCnonce = func() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// challenge is an authentication challenge from a WWW-Authenticate header
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses the values of WWW-Authenticate headers.
+// Each value can contain several comma-separated challenges.
+func parseChallenges(values []string) []challenge {
+	var challenges []challenge
+
+	for _, v := range values {
+		s := strings.TrimSpace(v)
+		for s != "" {
+			// A token that isn't followed by an equals sign starts a new challenge
+			token, rest := nextToken(s)
+			rest = strings.TrimSpace(rest)
+			if token == "" {
+				// Skip a character that can't be parsed
+				s = strings.TrimSpace(s[1:])
+				continue
+			}
+
+			if strings.HasPrefix(rest, "=") && len(challenges) > 0 {
+				var value string
+				value, rest = nextValue(strings.TrimSpace(rest[1:]))
+				challenges[len(challenges)-1].params[strings.ToLower(token)] = value
+			} else {
+				challenges = append(challenges, challenge{
+					scheme: strings.ToLower(token),
+					params: make(map[string]string),
+				})
+			}
+
+			s = strings.TrimLeft(strings.TrimSpace(rest), ",")
+			s = strings.TrimSpace(s)
+		}
+	}
+
+	return challenges
+}
+
+// nextToken returns the token at the start of s and the rest of s
+func nextToken(s string) (string, string) {
+	i := strings.IndexAny(s, " \t,=\"")
+	if i < 0 {
+		return s, ""
+	}
+
+	return s[:i], s[i:]
+}
+
+// nextValue returns the token or quoted string at the
+// start of s, unescaping quoted strings, and the rest of s
+func nextValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		return nextToken(s)
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	// The quoted string wasn't closed
+	return b.String(), ""
+}
@@ -0,0 +1,121 @@
+package patch
+
+import (
+	"context"
+	"crypto/md5"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChallenges(t *testing.T) {
+	challenges := parseChallenges([]string{
+		`Digest realm="test \"realm\"", qop="auth,auth-int", nonce=abc, Basic realm="basic"`,
+		`Bearer`,
+	})
+
+	require.Equal(t, []challenge{
+		{scheme: "digest", params: map[string]string{"realm": `test "realm"`, "qop": "auth,auth-int", "nonce": "abc"}},
+		{scheme: "basic", params: map[string]string{"realm": "basic"}},
+		{scheme: "bearer", params: map[string]string{}},
+	}, challenges)
+}
+
+func TestDigestResponse(t *testing.T) {
+	// The example from RFC 2617 section 3.5
+	d := &digestParams{
+		newHash:   md5.New,
+		algorithm: "MD5",
+		username:  "Mufasa",
+		password:  "Circle Of Life",
+		realm:     "testrealm@host.com",
+		nonce:     "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+		method:    http.MethodGet,
+		uri:       "/dir/index.html",
+		qop:       "auth",
+		nc:        "00000001",
+		cnonce:    "0a4f113b",
+	}
+
+	require.Equal(t, "6629fae49393a05397450978507c4ef1", d.response())
+}
+
+func TestChallengeAuth_basic(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "homer" || password != "donuts" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="springfield"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, err = w.Write(b)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	// The body is resent with the credentials
+	c := NewFromBaseClient(NewChallengeAuth(srv.Client(), "homer", "donuts"))
+	rsp, err := c.Post(context.Background(), srv.URL, "hello", nil)
+	require.NoError(t, err)
+	b, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, `"hello"`, b)
+
+	// Wrong credentials result in the second 401
+	c = NewFromBaseClient(NewChallengeAuth(srv.Client(), "homer", "beer"))
+	_, err = c.Post(context.Background(), srv.URL, "hello", nil)
+	require.Equal(t, BadStatusError(http.StatusUnauthorized), err)
+}
+
+func TestChallengeAuth_digest(t *testing.T) {
+	defer func(fn func() (string, error)) { newCnonce = fn }(newCnonce)
+	newCnonce = func() (string, error) { return "0a4f113b", nil }
+
+	want := `Digest username="Mufasa", realm="testrealm@host.com", ` +
+		`nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", algorithm=MD5, ` +
+		`response="6629fae49393a05397450978507c4ef1", qop=auth, nc=00000001, cnonce="0a4f113b", ` +
+		`opaque="5ccc069c403ebaf9f0171e9517f40e41"`
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != want {
+			w.Header().Set("WWW-Authenticate", `Digest realm="testrealm@host.com", qop="auth,auth-int", `+
+				`nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	c := NewFromBaseClient(NewChallengeAuth(srv.Client(), "Mufasa", "Circle Of Life"))
+	_, err := c.Get(context.Background(), srv.URL+"/dir/index.html", nil)
+	require.NoError(t, err)
+}
+
+func TestChallengeAuth_unsupportedAlgorithm(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("unauthorized")}
+	a := NewChallengeAuth(doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Header:     http.Header{"Www-Authenticate": {`Digest realm="r", nonce="n", algorithm=SHA-512`}},
+			Body:       body,
+		}, nil
+	}), "homer", "donuts")
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	rsp, err := a.Do(req)
+	require.EqualError(t, err, `unsupported digest algorithm "SHA-512"`)
+	require.Nil(t, rsp)
+	require.True(t, body.closed)
+}
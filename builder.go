@@ -0,0 +1,139 @@
+package patch
+
+import (
+	"context"
+	"net/url"
+)
+
+// RequestBuilder builds a Request using chained method calls. Each
+// method returns a new builder and leaves the receiver unchanged, so a
+// partially built builder can be used as a template for many requests.
+type RequestBuilder struct {
+	req        Request
+	query      url.Values
+	pathParams map[string]string
+}
+
+// NewRequestBuilder returns an empty RequestBuilder
+func NewRequestBuilder() *RequestBuilder {
+	return &RequestBuilder{}
+}
+
+// Method sets the request's method
+func (b *RequestBuilder) Method(method string) *RequestBuilder {
+	nb := b.clone()
+	nb.req.Method = method
+	return nb
+}
+
+// URL sets the request's URL
+func (b *RequestBuilder) URL(url string) *RequestBuilder {
+	nb := b.clone()
+	nb.req.URL = url
+	return nb
+}
+
+// Header sets a request header, replacing any existing values
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	nb := b.clone()
+	nb.req.setHeader(key, value)
+	return nb
+}
+
+// Query sets a query parameter, replacing any existing values
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	nb := b.clone()
+	if nb.query == nil {
+		nb.query = url.Values{}
+	}
+	nb.query.Set(key, value)
+	return nb
+}
+
+// PathParam sets the value of a {name} placeholder in the URL
+func (b *RequestBuilder) PathParam(name, value string) *RequestBuilder {
+	nb := b.clone()
+	if nb.pathParams == nil {
+		nb.pathParams = make(map[string]string)
+	}
+	nb.pathParams[name] = value
+	return nb
+}
+
+// Body sets the request's body
+func (b *RequestBuilder) Body(body interface{}) *RequestBuilder {
+	nb := b.clone()
+	nb.req.Body = body
+	return nb
+}
+
+// Encoder sets the encoder used for the request's body
+func (b *RequestBuilder) Encoder(enc Encoder) *RequestBuilder {
+	nb := b.clone()
+	nb.req.Encoder = enc
+	return nb
+}
+
+// Context sets the request's context
+func (b *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	nb := b.clone()
+	nb.req.Ctx = ctx
+	return nb
+}
+
+// Build returns a new Request. Changes to the Request
+// do not affect the builder or other built Requests.
+func (b *RequestBuilder) Build() *Request {
+	req := b.clone().req
+
+	if b.query != nil {
+		req.Query = cloneValues(b.query)
+	}
+
+	if b.pathParams != nil {
+		req.PathParams = cloneStrings(b.pathParams)
+	}
+
+	return &req
+}
+
+// Do builds the request, sends it using c and waits for the response
+func (b *RequestBuilder) Do(c *Client) (*Response, error) {
+	return c.Send(b.Build()).Response()
+}
+
+// clone returns a copy of the builder that
+// doesn't share any maps with the original
+func (b *RequestBuilder) clone() *RequestBuilder {
+	nb := *b
+
+	if b.req.Headers != nil {
+		nb.req.Headers = b.req.Headers.Clone()
+	}
+
+	if b.query != nil {
+		nb.query = cloneValues(b.query)
+	}
+
+	if b.pathParams != nil {
+		nb.pathParams = cloneStrings(b.pathParams)
+	}
+
+	return &nb
+}
+
+func cloneValues(values url.Values) url.Values {
+	c := make(url.Values, len(values))
+	for k, vs := range values {
+		c[k] = append([]string(nil), vs...)
+	}
+	return c
+}
+
+func cloneStrings(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,53 @@
+package patch
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users/1").Respond(http.StatusOK, `{"result": "Homer"}`).
+		Header("Content-Type", "application/json")
+	m.On(http.MethodGet, "/users/2").Respond(http.StatusOK, `{"result": "Marge"}`).
+		Header("Content-Type", "application/json")
+	c := NewFromBaseClient(m, WithBaseURL("http://example.com"))
+
+	template := NewRequestBuilder().
+		Method(http.MethodGet).
+		URL("/users/{id}").
+		Header("Authorization", "Bearer token").
+		Query("fields", "name")
+
+	rsp, err := template.PathParam("id", "1").Context(context.Background()).Do(c)
+	require.NoError(t, err)
+	var v testResult
+	require.NoError(t, rsp.Decode(&v))
+	require.Equal(t, "Homer", v.Result)
+
+	_, err = template.PathParam("id", "2").Query("fields", "age").Do(c)
+	require.NoError(t, err)
+
+	requests := m.Requests()
+	require.Equal(t, "http://example.com/users/1?fields=name", requests[0].URL.String())
+	require.Equal(t, "Bearer token", requests[0].Header.Get("Authorization"))
+	require.Equal(t, "http://example.com/users/2?fields=age", requests[1].URL.String())
+
+	// The template is unchanged
+	req := template.Build()
+	require.Nil(t, req.PathParams)
+	require.Equal(t, "name", req.Query.(url.Values).Get("fields"))
+
+	// Modifying a built request doesn't affect the builder
+	req.Headers.Set("Authorization", "Bearer other")
+	require.Equal(t, "Bearer token", template.Build().Headers.Get("Authorization"))
+
+	req = template.Method(http.MethodPost).Body("hello").Encoder(&EncoderText{}).Build()
+	require.Equal(t, http.MethodPost, req.Method)
+	require.Equal(t, "hello", req.Body)
+	require.IsType(t, &EncoderText{}, req.Encoder)
+}
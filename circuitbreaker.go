@@ -0,0 +1,125 @@
+package patch
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreaker is a Doer that stops calling the next Doer after a
+// number of consecutive failures. While the circuit is open, requests
+// fail fast with ErrCircuitOpen. Once OpenDuration has elapsed, a single
+// probe request is let through. If the probe succeeds the circuit
+// closes, otherwise it opens again.
+type CircuitBreaker struct {
+	// Next is the Doer that requests are passed to
+	Next Doer
+
+	// Threshold is the number of consecutive failures
+	// after which the circuit opens. Defaults to 5.
+	Threshold int
+
+	// OpenDuration is the time the circuit stays open
+	// before a probe request is allowed. Defaults to 30s.
+	OpenDuration time.Duration
+
+	// StatusValidator, if set, is used to count responses
+	// with unexpected status codes as failures. Transport
+	// errors are always counted as failures.
+	StatusValidator func(int) bool
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker returns a CircuitBreaker that wraps next
+func NewCircuitBreaker(next Doer, threshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Next:         next,
+		Threshold:    threshold,
+		OpenDuration: openDuration,
+	}
+}
+
+// Do sends the request to the next Doer unless the circuit is open
+func (cb *CircuitBreaker) Do(req *http.Request) (*http.Response, error) {
+	if !cb.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	rsp, err := cb.Next.Do(req)
+
+	failed := err != nil ||
+		(cb.StatusValidator != nil && !cb.StatusValidator(rsp.StatusCode))
+	cb.record(failed)
+
+	return rsp, err
+}
+
+// allow reports whether a request can be sent
+// and moves an expired open circuit to half-open.
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.openDuration() {
+			return false
+		}
+
+		// Let a single probe request through
+		cb.state = circuitHalfOpen
+		return true
+
+	case circuitHalfOpen:
+		// A probe is already in flight
+		return false
+	}
+
+	return true
+}
+
+// record updates the state of the circuit after a request
+func (cb *CircuitBreaker) record(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !failed {
+		cb.state = circuitClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || cb.failures >= cb.threshold() {
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+func (cb *CircuitBreaker) threshold() int {
+	if cb.Threshold > 0 {
+		return cb.Threshold
+	}
+
+	return 5
+}
+
+func (cb *CircuitBreaker) openDuration() time.Duration {
+	if cb.OpenDuration > 0 {
+		return cb.OpenDuration
+	}
+
+	return 30 * time.Second
+}
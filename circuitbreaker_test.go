@@ -0,0 +1,79 @@
+package patch
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	calls := 0
+	fail := true
+	next := doerFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	cb := NewCircuitBreaker(next, 2, 20*time.Millisecond)
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	// The circuit opens after two failures
+	for i := 0; i < 2; i++ {
+		_, err = cb.Do(req)
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrCircuitOpen))
+	}
+
+	_, err = cb.Do(req)
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+	require.Equal(t, 2, calls)
+
+	// A failed probe opens the circuit again
+	time.Sleep(25 * time.Millisecond)
+	_, err = cb.Do(req)
+	require.False(t, errors.Is(err, ErrCircuitOpen))
+	require.Equal(t, 3, calls)
+	_, err = cb.Do(req)
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+
+	// A successful probe closes the circuit
+	fail = false
+	time.Sleep(25 * time.Millisecond)
+	_, err = cb.Do(req)
+	require.NoError(t, err)
+	_, err = cb.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, 5, calls)
+}
+
+func TestCircuitBreaker_statusValidator(t *testing.T) {
+	next := doerFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable}, nil
+	})
+
+	cb := NewCircuitBreaker(next, 1, time.Minute)
+	cb.StatusValidator = DefaultStatusValidator
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	rsp, err := cb.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, rsp.StatusCode)
+
+	_, err = cb.Do(req)
+	require.True(t, errors.Is(err, ErrCircuitOpen))
+}
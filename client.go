@@ -2,8 +2,14 @@ package patch
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Client is an HTTP client that uses the BaseClient to send requests
@@ -12,6 +18,98 @@ type Client struct {
 	DefaultEncoder  Encoder
 	StatusValidator func(int) bool
 	BaseClient      Doer
+
+	// ResponseValidator, if set, is used instead of the StatusValidator.
+	// It must not read the response body.
+	ResponseValidator func(*http.Response) bool
+
+	// ErrorDecoder, if set, is called with the status and body of a
+	// response that fails validation. The error it returns is used
+	// instead of a BadStatusError. If it returns nil or the body
+	// can't be read, a BadStatusError is returned as usual. The body
+	// is buffered so it can still be read from the Response.
+	ErrorDecoder func(status int, body []byte) error
+
+	// AllowedMethods are extension methods, such as the WebDAV
+	// PROPFIND, that are allowed in addition to the standard methods.
+	AllowedMethods []string
+
+	// BaseContext, if set, is used for requests that
+	// don't have a context, instead of context.Background().
+	BaseContext context.Context
+
+	// TokenSource, if set, is called before each request to obtain
+	// a bearer token which is sent in the Authorization header.
+	TokenSource func(context.Context) (string, error)
+
+	// MethodTimeouts are time limits for requests with the given
+	// methods, applied to requests whose context has no deadline.
+	// The http.Client's timeout still applies if it is shorter.
+	MethodTimeouts map[string]time.Duration
+
+	// MaxResponseBytes limits the size of response bodies
+	// read by the Response helpers. Zero means no limit.
+	MaxResponseBytes int64
+
+	// PathJoin appends request URLs to the BaseURL's path instead of
+	// resolving them as references. When resolving, a request URL
+	// with a leading slash replaces the BaseURL's path.
+	PathJoin bool
+
+	// BodyTransformer, if set, is applied to response bodies before
+	// they are decoded, e.g. to strip an XSSI protection prefix. It
+	// doesn't affect BodyBytes or BodyString.
+	BodyTransformer func([]byte) ([]byte, error)
+
+	// TransparentGzip sets an Accept-Encoding header listing the
+	// built-in and registered decompressors (gzip, deflate, br and
+	// zstd by default) on requests without one, and decompresses
+	// encoded responses. Unlike the implicit compression of
+	// http.Transport, it works with any Doer and with requests that
+	// set their own Accept-Encoding header.
+	TransparentGzip bool
+
+	// DefaultResponseContentType is assumed when decoding
+	// responses that don't have a Content-Type header.
+	DefaultResponseContentType string
+
+	// AutoAccept sets an Accept header listing JSON, the
+	// DefaultResponseContentType and the content types of the
+	// registered decoders on requests without one.
+	AutoAccept bool
+
+	// DefaultQuery is added to the query string of every request.
+	// Values in the request's URL or Query take precedence.
+	DefaultQuery url.Values
+
+	// HeaderFuncs are called for each request to compute the value
+	// of the header with the given key. If a function returns an error,
+	// the request is aborted. Headers set on the request take precedence.
+	HeaderFuncs map[string]func(*Request) (string, error)
+
+	// RequestIDHeader, if set, is the header in which a random
+	// request ID is sent, unless the request already has one.
+	RequestIDHeader string
+
+	// RequestHooks are called in order with the
+	// request before it is sent. If a hook returns
+	// an error, the request is aborted.
+	RequestHooks []func(*http.Request) error
+
+	// ResponseHooks are called in order with the
+	// response before it is validated. If a hook
+	// returns an error, it is returned with the
+	// response.
+	ResponseHooks []func(*http.Response) error
+
+	decoders map[string]Decoder
+
+	// decompressors are used by TransparentGzip in
+	// preference to the built-in decompressors
+	decompressors map[string]Decompressor
+
+	// sem limits the number of concurrent requests if not nil
+	sem chan struct{}
 }
 
 // Doer executes HTTP requests. It is implemented by http.Client{}.
@@ -44,6 +142,75 @@ func NewFromBaseClient(baseClient Doer, opts ...Option) *Client {
 	return c
 }
 
+// Clone returns a copy of the client with the options applied.
+// The copy shares the same BaseClient, so options that modify the
+// base client, such as WithTimeout, also affect the original client.
+// To avoid this, pass WithBaseClient before any such options.
+func (c *Client) Clone(opts ...Option) *Client {
+	clone := *c
+
+	clone.decoders = make(map[string]Decoder, len(c.decoders))
+	for mt, dec := range c.decoders {
+		clone.decoders[mt] = dec
+	}
+
+	clone.decompressors = make(map[string]Decompressor, len(c.decompressors))
+	for enc, dec := range c.decompressors {
+		clone.decompressors[enc] = dec
+	}
+
+	clone.DefaultQuery = make(url.Values, len(c.DefaultQuery))
+	for key, vs := range c.DefaultQuery {
+		clone.DefaultQuery[key] = append([]string(nil), vs...)
+	}
+
+	clone.MethodTimeouts = make(map[string]time.Duration, len(c.MethodTimeouts))
+	for method, d := range c.MethodTimeouts {
+		clone.MethodTimeouts[method] = d
+	}
+
+	clone.HeaderFuncs = make(map[string]func(*Request) (string, error), len(c.HeaderFuncs))
+	for key, fn := range c.HeaderFuncs {
+		clone.HeaderFuncs[key] = fn
+	}
+
+	// Copy the slices so that appending to them
+	// doesn't modify the original client.
+	clone.AllowedMethods = append([]string(nil), c.AllowedMethods...)
+	clone.RequestHooks = append([]func(*http.Request) error(nil), c.RequestHooks...)
+	clone.ResponseHooks = append([]func(*http.Response) error(nil), c.ResponseHooks...)
+
+	for _, opt := range opts {
+		opt(&clone)
+	}
+
+	return &clone
+}
+
+// RegisterDecoder sets the Decoder used by Response.Decode for
+// responses with the given Content-Type. The content type is matched
+// case-insensitively and any parameters (e.g. charset) are ignored.
+// It is not safe to call RegisterDecoder while requests are in flight.
+func (c *Client) RegisterDecoder(contentType string, dec Decoder) {
+	if c.decoders == nil {
+		c.decoders = make(map[string]Decoder)
+	}
+
+	c.decoders[mediaType(contentType)] = dec
+}
+
+// RegisterDecompressor sets the Decompressor used by TransparentGzip
+// for responses with the given Content-Encoding, which is matched
+// case-insensitively. It is not safe to call RegisterDecompressor
+// while requests are in flight.
+func (c *Client) RegisterDecompressor(encoding string, dec Decompressor) {
+	if c.decompressors == nil {
+		c.decompressors = make(map[string]Decompressor)
+	}
+
+	c.decompressors[strings.ToLower(encoding)] = dec
+}
+
 // Get performs a GET request
 func (c *Client) Get(ctx context.Context, url string, v interface{}) (*Response, error) {
 	r := &Request{Ctx: ctx, Method: http.MethodGet, URL: url}
@@ -109,7 +276,52 @@ func (c *Client) Delete(ctx context.Context, url string, body interface{}, v int
 	return rsp, nil
 }
 
-// Send performs the HTTP request and returns a Future
+// Head performs a HEAD request
+func (c *Client) Head(ctx context.Context, url string) (*Response, error) {
+	r := &Request{Ctx: ctx, Method: http.MethodHead, URL: url}
+	return c.Send(r).Response()
+}
+
+// Exists performs a HEAD request and returns true if the status
+// is 2xx or false if the status is 404. Other statuses result in a
+// BadStatusError. The client's status validator is not used.
+func (c *Client) Exists(ctx context.Context, url string) (bool, error) {
+	r := &Request{Ctx: ctx, Method: http.MethodHead, URL: url, SkipStatusValidation: true}
+	rsp, err := c.Send(r).Response()
+	if err != nil {
+		return false, err
+	}
+	_ = rsp.Drain()
+
+	switch {
+	case rsp.StatusCode >= 200 && rsp.StatusCode < 300:
+		return true, nil
+	case rsp.StatusCode == http.StatusNotFound:
+		return false, nil
+	default:
+		return false, BadStatusError(rsp.StatusCode)
+	}
+}
+
+// Get performs a GET request and decodes the response into a new
+// value of type T. The decoder is inferred from the Content-Type.
+func Get[T any](ctx context.Context, c *Client, url string) (T, *Response, error) {
+	var v T
+	rsp, err := c.Get(ctx, url, &v)
+	return v, rsp, err
+}
+
+// Post performs a POST request and decodes the response into a new
+// value of type T. The decoder is inferred from the Content-Type.
+func Post[T any](ctx context.Context, c *Client, url string, body interface{}) (T, *Response, error) {
+	var v T
+	rsp, err := c.Post(ctx, url, body, &v)
+	return v, rsp, err
+}
+
+// Send performs the HTTP request and returns a Future. Cancelling
+// the request's context aborts the request, including one that is
+// blocked in the BaseClient, and the Future returns the context's error.
 func (c *Client) Send(request *Request) *Future {
 	done := make(chan struct{})
 	ftr := &Future{done: done}
@@ -122,8 +334,26 @@ func (c *Client) Send(request *Request) *Future {
 	return ftr
 }
 
-func (c *Client) do(request *Request) (*Response, error) {
-	if err := request.validate(); err != nil {
+// SendCancelable performs the HTTP request and returns a Future
+// and a function that aborts the request when called. The cancel
+// function should be called once the response is no longer needed
+// to release resources associated with the request's context.
+func (c *Client) SendCancelable(request *Request) (*Future, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(request.context(c.BaseContext))
+
+	// Copy the request so the caller's request isn't modified
+	r := *request
+	r.Ctx = ctx
+
+	return c.Send(&r), cancel
+}
+
+// BuildRequest returns the *http.Request that would be sent for the
+// request, with the URL resolved, body encoded, headers set and request
+// hooks applied. The request is not sent. This is useful for debugging
+// and for asserting on exactly what would be sent in tests.
+func (c *Client) BuildRequest(request *Request) (*http.Request, error) {
+	if err := request.validate(c.AllowedMethods); err != nil {
 		return nil, err
 	}
 
@@ -131,18 +361,46 @@ func (c *Client) do(request *Request) (*Response, error) {
 
 	path := request.URL
 
+	if request.PathParams != nil {
+		var err error
+		if path, err = expandPath(path, request.PathParams); err != nil {
+			return nil, err
+		}
+	}
+
 	if c.BaseURL != "" {
 		base, err := url.Parse(c.BaseURL)
 		if err != nil {
 			return nil, err
 		}
 
-		ref, err := url.Parse(request.URL)
+		ref, err := url.Parse(path)
 		if err != nil {
 			return nil, err
 		}
 
-		path = base.ResolveReference(ref).String()
+		if c.PathJoin {
+			joined, err := joinURL(base, ref)
+			if err != nil {
+				return nil, err
+			}
+			path = joined.String()
+		} else {
+			path = base.ResolveReference(ref).String()
+		}
+	}
+
+	if c.DefaultQuery != nil {
+		path = withDefaultQuery(path, c.DefaultQuery)
+	}
+
+	if request.Query != nil {
+		values, err := toURLValues(request.Query, "url")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode query: %w", err)
+		}
+
+		path = withQuery(path, values)
 	}
 
 	body, contentType, err := request.prepareBody(c.DefaultEncoder)
@@ -150,39 +408,289 @@ func (c *Client) do(request *Request) (*Response, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(request.Method, path, body)
+	req, err := http.NewRequestWithContext(request.context(c.BaseContext), request.Method, path, body)
 	if err != nil {
 		return nil, err
 	}
 
-	if request.Ctx != nil {
-		req = req.WithContext(request.Ctx)
+	if getBody := request.getBody(); getBody != nil {
+		req.GetBody = getBody
 	}
 
 	if request.Headers != nil {
-		req.Header = request.Headers
+		req.Header = request.Headers.Clone()
+	}
+
+	if request.Host != "" {
+		req.Host = request.Host
+	}
+
+	if request.Chunked && req.Body != nil {
+		req.ContentLength = -1
+		req.TransferEncoding = []string{"chunked"}
+	}
+
+	if request.Trailers != nil {
+		req.Trailer = request.Trailers.Clone()
+		if req.Body != nil {
+			req.ContentLength = -1
+		}
+	}
+
+	// Set the Idempotency-Key header (unless an override was provided in request)
+	if request.IdempotencyKey != "" && req.Header.Get("Idempotency-Key") == "" {
+		req.Header.Set("Idempotency-Key", request.IdempotencyKey)
 	}
 
-	// Set the Content-Type header (unless an override was provided in request)
+	// Set the Content-Type header (unless an override was provided in request).
+	// The precedence is: request header > request encoder > client encoder.
 	if contentType != "" && req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", contentType)
 	}
 
+	// Set the Accept-Encoding header (unless an override was provided in request)
+	if c.TransparentGzip && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", c.acceptEncoding())
+	}
+
+	// Set the Accept header (unless an override was provided in request)
+	if c.AutoAccept && req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", c.accept())
+	}
+
+	for key, fn := range c.HeaderFuncs {
+		if req.Header.Get(key) != "" {
+			continue
+		}
+
+		value, err := fn(request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute %s header: %w", key, err)
+		}
+
+		req.Header.Set(key, value)
+	}
+
+	// Set the request ID header (unless an override was provided in request)
+	if c.RequestIDHeader != "" && req.Header.Get(c.RequestIDHeader) == "" {
+		id, err := newRequestID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate request ID: %w", err)
+		}
+
+		req.Header.Set(c.RequestIDHeader, id)
+	}
+
+	// Set the Authorization header (unless an override was provided in request)
+	if c.TokenSource != nil && req.Header.Get("Authorization") == "" {
+		token, err := c.TokenSource(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	for key, values := range request.AddHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	for _, hook := range c.RequestHooks {
+		if err := hook(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+func (c *Client) do(request *Request) (*Response, error) {
+	req, err := c.BuildRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.send(req, request.SkipStatusValidation)
+}
+
+// DoRequest sends a prepared *http.Request, e.g. one built by another
+// library, and wraps the result in a *Response. The request is sent
+// as-is: the BaseURL, default headers, token source and request hooks
+// are not applied. The method timeouts, concurrency limit, transparent
+// decompression, response hooks and validators are applied as usual.
+func (c *Client) DoRequest(req *http.Request) (*Response, error) {
+	return c.send(req, false)
+}
+
+// send sends the request using the BaseClient
+// and validates the response unless told to skip
+func (c *Client) send(req *http.Request, skipStatusValidation bool) (*Response, error) {
 	/* Make the HTTP request */
 
+	// The context is canceled when the body is closed
+	// so that the timeout applies to reading the body.
+	var cancel context.CancelFunc
+	if d := c.MethodTimeouts[req.Method]; d > 0 {
+		if _, ok := req.Context().Deadline(); !ok {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(req.Context(), d)
+			req = req.WithContext(ctx)
+		}
+	}
+
+	if c.sem != nil {
+		select {
+		case c.sem <- struct{}{}:
+		case <-req.Context().Done():
+			err := req.Context().Err()
+			if cancel != nil {
+				cancel()
+			}
+			if err == context.DeadlineExceeded {
+				return nil, &TimeoutError{Err: err}
+			}
+			return nil, err
+		}
+	}
+
+	start := time.Now()
 	rsp, err := c.BaseClient.Do(req)
+
 	if err != nil {
+		if c.sem != nil {
+			<-c.sem
+		}
+
+		if cancel != nil {
+			cancel()
+		}
+
+		// The http.Client doesn't consistently wrap
+		// context.DeadlineExceeded when its timeout elapses.
+		if IsTimeout(err) {
+			return nil, &TimeoutError{Err: err}
+		}
+
 		return nil, err
 	}
 
+	if cancel != nil {
+		rsp.Body = &cancelCloser{ReadCloser: rsp.Body, cancel: cancel}
+	}
+
+	// Hold the slot until the body has been read and closed
+	if c.sem != nil {
+		if rsp.Body == nil {
+			<-c.sem
+		} else {
+			rsp.Body = &semCloser{ReadCloser: rsp.Body, sem: c.sem}
+		}
+	}
+
+	if c.TransparentGzip {
+		decompress(rsp, c.decompressors)
+	}
+
 	// From this point on, all return values should return response, even if there's an error
 	// so that the caller can see all of the information about the response.
-	response := &Response{Response: rsp}
+	response := &Response{
+		Response:   rsp,
+		Duration:   time.Since(start),
+		client:     c,
+		requestURL: req.URL.String(),
+	}
+
+	if c.RequestIDHeader != "" {
+		response.requestID = req.Header.Get(c.RequestIDHeader)
+	}
+
+	for _, hook := range c.ResponseHooks {
+		if err := hook(rsp); err != nil {
+			return response, err
+		}
+	}
 
-	// Execute the status validator if set
-	if c.StatusValidator != nil && !c.StatusValidator(rsp.StatusCode) {
-		return response, BadStatusError(rsp.StatusCode)
+	if skipStatusValidation {
+		return response, nil
+	}
+
+	// Execute the response validator if set, otherwise the status validator
+	if c.ResponseValidator != nil {
+		if !c.ResponseValidator(rsp) {
+			return response, c.statusError(response)
+		}
+	} else if c.StatusValidator != nil && !c.StatusValidator(rsp.StatusCode) {
+		return response, c.statusError(response)
 	}
 
 	return response, nil
 }
+
+// statusError returns the error for a response that failed validation
+func (c *Client) statusError(rsp *Response) error {
+	if c.ErrorDecoder == nil {
+		return BadStatusError(rsp.StatusCode)
+	}
+
+	body, err := rsp.BodyBytes()
+	if err != nil {
+		return BadStatusError(rsp.StatusCode)
+	}
+
+	if err := c.ErrorDecoder(rsp.StatusCode, body); err != nil {
+		return err
+	}
+
+	return BadStatusError(rsp.StatusCode)
+}
+
+// accept returns the content types that the client is configured to
+// decode: JSON, the DefaultResponseContentType and the registered
+// decoders. The other built-in decoders are not advertised, so that
+// a server doesn't pick a format the caller isn't expecting.
+func (c *Client) accept() string {
+	seen := map[string]bool{"application/json": true}
+	if c.DefaultResponseContentType != "" {
+		seen[mediaType(c.DefaultResponseContentType)] = true
+	}
+	for mt := range c.decoders {
+		seen[mt] = true
+	}
+
+	types := make([]string, 0, len(seen))
+	for mt := range seen {
+		types = append(types, mt)
+	}
+
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// semCloser releases a concurrency slot when the body is closed
+type semCloser struct {
+	io.ReadCloser
+	sem  chan struct{}
+	once sync.Once
+}
+
+// Close closes the body and releases the slot
+func (c *semCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(func() { <-c.sem })
+	return err
+}
+
+// cancelCloser cancels a context when the body is closed
+type cancelCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context
+func (c *cancelCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
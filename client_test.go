@@ -1,14 +1,26 @@
 package patch
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 func TestClient_methodHelpers(t *testing.T) {
@@ -50,6 +62,32 @@ func TestClient_methodHelpers(t *testing.T) {
 	rspBody, err = rsp.BodyString()
 	require.NoError(t, err)
 	require.Equal(t, "DELETE /foo", rspBody)
+
+	rsp, err = c.Head(context.Background(), srv.URL+"/foo")
+	require.NoError(t, err)
+	require.Equal(t, http.MethodHead, rsp.Request.Method)
+}
+
+func TestClient_Exists(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodHead, "/found").Respond(http.StatusOK, "")
+	m.On(http.MethodHead, "/missing").Respond(http.StatusNotFound, "")
+	m.On(http.MethodHead, "/broken").Respond(http.StatusInternalServerError, "")
+	c := NewFromBaseClient(m)
+
+	exists, err := c.Exists(context.Background(), "/found")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = c.Exists(context.Background(), "/missing")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	_, err = c.Exists(context.Background(), "/broken")
+	require.Equal(t, BadStatusError(http.StatusInternalServerError), err)
+
+	_, err = c.Exists(context.Background(), "/unknown")
+	require.Error(t, err)
 }
 
 func TestClient_invalidMethod(t *testing.T) {
@@ -62,6 +100,20 @@ func TestClient_invalidMethod(t *testing.T) {
 	require.True(t, errors.As(err, &target))
 }
 
+func TestClient_allowedMethods(t *testing.T) {
+	m := NewMockDoer()
+	m.On("PROPFIND", "/files").Respond(207, "")
+
+	_, err := NewFromBaseClient(m).Send(&Request{Method: "PROPFIND", URL: "/files"}).Response()
+	require.Equal(t, InvalidMethodError("PROPFIND"), err)
+
+	c := NewFromBaseClient(m, WithAllowedMethods("PROPFIND", "MKCOL"))
+	rsp, err := c.Send(&Request{Method: "PROPFIND", URL: "/files"}).Response()
+	require.NoError(t, err)
+	require.Equal(t, 207, rsp.StatusCode)
+	require.Equal(t, "PROPFIND", m.Requests()[0].Method)
+}
+
 func TestClient_jsonDecoder(t *testing.T) {
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -99,3 +151,1545 @@ func TestClient_jsonDecoder(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "bar", v.Foo)
 }
+
+func TestClient_bearerToken(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.Header.Get("Authorization")))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	// Static token
+	c := NewFromBaseClient(srv.Client(), WithBearerToken("abc"))
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer abc", rspBody)
+
+	// Dynamic token source is called for every request
+	n := 0
+	c = NewFromBaseClient(srv.Client(), WithTokenSource(func(ctx context.Context) (string, error) {
+		n++
+		return fmt.Sprintf("token%d", n), nil
+	}))
+	for i := 1; i <= 2; i++ {
+		rsp, err = c.Get(context.Background(), srv.URL, nil)
+		require.NoError(t, err)
+		rspBody, err = rsp.BodyString()
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("Bearer token%d", i), rspBody)
+	}
+
+	// Errors from the token source abort the request
+	c = NewFromBaseClient(srv.Client(), WithTokenSource(func(ctx context.Context) (string, error) {
+		return "", errors.New("token expired")
+	}))
+	_, err = c.Get(context.Background(), srv.URL, nil)
+	require.Error(t, err)
+}
+
+func TestClient_registerDecoder(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "Text/CSV; charset=utf-8")
+		_, err := w.Write([]byte("a,b,c"))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	dec := &csvDecoder{}
+	c := NewFromBaseClient(srv.Client(), WithDecoder("text/csv", dec))
+
+	var v []string
+	_, err := c.Get(context.Background(), srv.URL, &v)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, v)
+}
+
+type csvDecoder struct{}
+
+func (d *csvDecoder) Name() string { return "CSV" }
+
+func (d *csvDecoder) Decode(data []byte, v interface{}) error {
+	*(v.(*[]string)) = strings.Split(string(data), ",")
+	return nil
+}
+
+func TestClient_yaml(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/yaml", r.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "text/yaml")
+		_, err := io.Copy(w, r.Body)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithEncoder(&EncoderYAML{}))
+
+	type body struct {
+		Foo string `yaml:"foo"`
+		Bar int    `yaml:"bar"`
+	}
+
+	var v body
+	rsp, err := c.Post(context.Background(), srv.URL, &body{Foo: "foo", Bar: 5}, &v)
+	require.NoError(t, err)
+	require.Equal(t, body{Foo: "foo", Bar: 5}, v)
+
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "foo: foo\nbar: 5\n", rspBody)
+}
+
+func TestClient_text(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "text/plain", r.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, err := io.Copy(w, r.Body)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithEncoder(&EncoderText{}))
+
+	var s string
+	rsp, err := c.Post(context.Background(), srv.URL, "hello world", &s)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", s)
+
+	var b []byte
+	require.NoError(t, rsp.Decode(&b))
+	require.Equal(t, []byte("hello world"), b)
+
+	_, err = c.Post(context.Background(), srv.URL, 5, nil)
+	require.Error(t, err)
+}
+
+func TestResponse_cookie(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+
+	cookie, err := rsp.Cookie("session")
+	require.NoError(t, err)
+	require.Equal(t, "abc", cookie.Value)
+	require.Equal(t, "dark", rsp.CookieValue("theme"))
+
+	_, err = rsp.Cookie("missing")
+	require.True(t, errors.Is(err, http.ErrNoCookie))
+	require.Equal(t, "", rsp.CookieValue("missing"))
+}
+
+func TestClient_pathParams(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.RequestURI))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithBaseURL(srv.URL))
+
+	rsp, err := c.Send(&Request{
+		Method:     http.MethodGet,
+		URL:        "/users/{id}/posts/{postID}",
+		PathParams: map[string]string{"id": "a b", "postID": "5"},
+	}).Response()
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "/users/a%20b/posts/5", rspBody)
+}
+
+func TestClient_nilContext(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithTimeout(20*time.Millisecond))
+
+	// The client timeout applies to requests without a context
+	start := time.Now()
+	_, err := c.Send(&Request{Method: http.MethodGet, URL: srv.URL}).Response()
+	require.Error(t, err)
+	require.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+}
+
+func TestClient_duration(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, int64(rsp.Duration), int64(20*time.Millisecond))
+}
+
+func TestClient_autoAccept(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.Header.Get("Accept")))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithAutoAccept(), WithDecoder("text/csv", &csvDecoder{}))
+
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "application/json, text/csv", rspBody)
+
+	// An explicit Accept header takes precedence
+	rsp, err = c.Send(&Request{
+		Method:  http.MethodGet,
+		URL:     srv.URL,
+		Headers: http.Header{"Accept": {"text/csv"}},
+	}).Response()
+	require.NoError(t, err)
+	rspBody, err = rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "text/csv", rspBody)
+
+	// The default response content type is accepted
+	c2 := c.Clone(WithDefaultResponseContentType("application/yaml; charset=utf-8"))
+	require.Equal(t, "application/json, application/yaml, text/csv", c2.accept())
+}
+
+func TestClient_rawBody(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(fmt.Sprintf("%d %q %s", r.ContentLength, r.Header.Get("Content-Type"), b)))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	tests := []struct {
+		name string
+		body interface{}
+		want string
+	}{
+		{
+			name: "[]byte",
+			body: []byte(`{"foo":"bar"}`),
+			want: `13 "" {"foo":"bar"}`,
+		},
+		{
+			name: "strings.Reader",
+			body: strings.NewReader("hello"),
+			want: `5 "" hello`,
+		},
+		{
+			name: "unknown length reader",
+			body: io.MultiReader(strings.NewReader("hello")),
+			want: `-1 "" hello`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := c.Post(context.Background(), srv.URL, tt.body, nil)
+			require.NoError(t, err)
+			rspBody, err := rsp.BodyString()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, rspBody)
+		})
+	}
+
+	// Byte slices go through EncoderText, whether it is set on the request or the client
+	rsp, err := c.Send(&Request{
+		Method:  http.MethodPost,
+		URL:     srv.URL,
+		Body:    []byte("hello"),
+		Encoder: &EncoderText{},
+	}).Response()
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, `5 "text/plain" hello`, rspBody)
+
+	c = NewFromBaseClient(srv.Client(), WithEncoder(EncoderText{CustomContentType: "text/csv"}))
+	rsp, err = c.Post(context.Background(), srv.URL, []byte("a,b"), nil)
+	require.NoError(t, err)
+	rspBody, err = rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, `3 "text/csv" a,b`, rspBody)
+}
+
+func TestClient_Clone(t *testing.T) {
+	c := New(WithBaseURL("http://a.example.com"), WithDecoder("text/csv", &csvDecoder{}))
+	clone := c.Clone(WithBaseURL("http://b.example.com"), WithStatusValidator(nil))
+	clone.RegisterDecoder("text/xml", &csvDecoder{})
+
+	require.Equal(t, "http://a.example.com", c.BaseURL)
+	require.NotNil(t, c.StatusValidator)
+	require.Len(t, c.decoders, 1)
+
+	require.Equal(t, "http://b.example.com", clone.BaseURL)
+	require.Nil(t, clone.StatusValidator)
+	require.Len(t, clone.decoders, 2)
+	require.Same(t, c.BaseClient, clone.BaseClient)
+}
+
+func TestClient_contentTypePrecedence(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.Header.Get("Content-Type")))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithEncoder(&EncoderJSON{}))
+
+	tests := []struct {
+		name    string
+		headers http.Header
+		encoder Encoder
+		body    interface{}
+		want    string
+	}{
+		{
+			name: "client encoder",
+			body: map[string]string{},
+			want: "application/json; charset=utf-8",
+		},
+		{
+			name:    "client encoder with other headers",
+			headers: http.Header{"X-Foo": {"bar"}},
+			body:    map[string]string{},
+			want:    "application/json; charset=utf-8",
+		},
+		{
+			name:    "request encoder",
+			encoder: &EncoderFormURL{},
+			body:    map[string]string{},
+			want:    "application/x-www-form-urlencoded",
+		},
+		{
+			name:    "request header overrides client encoder",
+			headers: http.Header{"Content-Type": {"application/vnd.api+json"}},
+			body:    map[string]string{},
+			want:    "application/vnd.api+json",
+		},
+		{
+			name:    "request header overrides request encoder",
+			headers: http.Header{"Content-Type": {"application/vnd.api+json"}},
+			encoder: &EncoderFormURL{},
+			body:    map[string]string{},
+			want:    "application/vnd.api+json",
+		},
+		{
+			name: "no body",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := tt.headers.Clone()
+			rsp, err := c.Send(&Request{
+				Method:  http.MethodPost,
+				URL:     srv.URL,
+				Headers: tt.headers,
+				Body:    tt.body,
+				Encoder: tt.encoder,
+			}).Response()
+			require.NoError(t, err)
+			rspBody, err := rsp.BodyString()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, rspBody)
+
+			// The request's headers are not modified
+			require.Equal(t, headers, tt.headers)
+		})
+	}
+}
+
+func TestClient_addHeaders(t *testing.T) {
+	c := New(WithAutoAccept(), WithHeaderFunc("X-Tenant", func(*Request) (string, error) {
+		return "acme", nil
+	}))
+
+	req, err := c.BuildRequest(&Request{
+		Method: http.MethodPost,
+		URL:    "http://example.com",
+		Body:   map[string]string{"name": "Homer"},
+		AddHeaders: http.Header{
+			"X-Custom": {"a", "b"},
+			"X-Tenant": {"other"},
+		},
+	})
+	require.NoError(t, err)
+
+	// Computed headers are kept
+	require.Equal(t, "application/json; charset=utf-8", req.Header.Get("Content-Type"))
+	require.Equal(t, c.accept(), req.Header.Get("Accept"))
+
+	// Values are appended to the computed values
+	require.Equal(t, []string{"a", "b"}, req.Header.Values("X-Custom"))
+	require.Equal(t, []string{"acme", "other"}, req.Header.Values("X-Tenant"))
+}
+
+func TestClient_noRedirects(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithNoRedirects(), WithStatusValidator(nil))
+
+	rsp, err := c.Get(context.Background(), srv.URL+"/old", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, rsp.StatusCode)
+	require.Equal(t, "/new", rsp.Header.Get("Location"))
+}
+
+func TestClient_msgpack(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/msgpack", r.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "application/msgpack")
+		_, err := io.Copy(w, r.Body)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithEncoder(&EncoderMsgpack{}))
+
+	type body struct {
+		Foo string `msgpack:"foo"`
+		Bar int    `msgpack:"bar"`
+	}
+
+	var v body
+	rsp, err := c.Post(context.Background(), srv.URL, &body{Foo: "foo", Bar: 5}, &v)
+	require.NoError(t, err)
+	require.Equal(t, body{Foo: "foo", Bar: 5}, v)
+
+	v = body{}
+	require.NoError(t, rsp.DecodeMsgpack(&v))
+	require.Equal(t, body{Foo: "foo", Bar: 5}, v)
+}
+
+func TestClient_proto(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		_, err := io.Copy(w, r.Body)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithEncoder(&EncoderProto{}))
+
+	v := &wrapperspb.StringValue{}
+	_, err := c.Post(context.Background(), srv.URL, wrapperspb.String("foo"), v)
+	require.NoError(t, err)
+	require.Equal(t, "foo", v.GetValue())
+
+	// Non-proto bodies cannot be encoded
+	_, err = c.Post(context.Background(), srv.URL, map[string]string{}, nil)
+	require.Error(t, err)
+}
+
+func TestClient_responseValidator(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.Header().Set("X-Api-Error", "true")
+		}
+		_, err := w.Write([]byte("body"))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithResponseValidator(func(rsp *http.Response) bool {
+		return rsp.Header.Get("X-Api-Error") == ""
+	}))
+
+	_, err := c.Get(context.Background(), srv.URL+"/ok", nil)
+	require.NoError(t, err)
+
+	rsp, err := c.Get(context.Background(), srv.URL+"/error", nil)
+	var target BadStatusError
+	require.True(t, errors.As(err, &target))
+
+	// The body is still available
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "body", rspBody)
+}
+
+func TestClient_errorDecoder(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		if r.URL.Path == "/message" {
+			_, err := w.Write([]byte(`{"message": "invalid name"}`))
+			require.NoError(t, err)
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	var statuses []int
+	c := NewFromBaseClient(srv.Client(), WithErrorDecoder(func(status int, body []byte) error {
+		statuses = append(statuses, status)
+		if len(body) == 0 {
+			return nil
+		}
+
+		var e testError
+		if err := json.Unmarshal(body, &e); err != nil {
+			return err
+		}
+		return errors.New(e.Message)
+	}))
+
+	rsp, err := c.Get(context.Background(), srv.URL+"/message", nil)
+	require.EqualError(t, err, "invalid name")
+
+	// The body is still available
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, `{"message": "invalid name"}`, rspBody)
+
+	// A nil error falls back to a BadStatusError
+	_, err = c.Get(context.Background(), srv.URL+"/empty", nil)
+	require.Equal(t, BadStatusError(http.StatusBadRequest), err)
+	require.Equal(t, []int{http.StatusBadRequest, http.StatusBadRequest}, statuses)
+}
+
+func TestClient_hooks(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Signature", r.Header.Get("X-Signature"))
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	var audited []string
+	c := NewFromBaseClient(srv.Client(),
+		WithRequestHook(func(req *http.Request) error {
+			req.Header.Set("X-Signature", "signed")
+			return nil
+		}),
+		WithResponseHook(func(rsp *http.Response) error {
+			audited = append(audited, rsp.Header.Get("X-Signature"))
+			return nil
+		}),
+	)
+
+	_, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"signed"}, audited)
+
+	// A request hook error aborts the request
+	hookErr := errors.New("hook failed")
+	c = c.Clone(WithRequestHook(func(req *http.Request) error {
+		return hookErr
+	}))
+	_, err = c.Get(context.Background(), srv.URL, nil)
+	require.True(t, errors.Is(err, hookErr))
+	require.Len(t, audited, 1)
+}
+
+func TestClient_requestEditor(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "http://mirror.example.com/users").Respond(http.StatusOK, "")
+
+	var calls []string
+	c := NewFromBaseClient(m,
+		WithBaseURL("http://example.com"),
+		WithRequestEditor(func(ctx context.Context, req *http.Request) error {
+			calls = append(calls, "editor "+OperationNameFromContext(ctx))
+			req.URL.Host = "mirror.example.com"
+			return nil
+		}),
+		WithRequestHook(func(req *http.Request) error {
+			calls = append(calls, "hook "+req.URL.Host)
+			return nil
+		}),
+	)
+
+	ctx := WithOperationName(context.Background(), "ListUsers")
+	_, err := c.Get(ctx, "/users", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"editor ListUsers", "hook mirror.example.com"}, calls)
+
+	// An editor error aborts the request
+	editorErr := errors.New("editor failed")
+	c = c.Clone(WithRequestEditor(func(context.Context, *http.Request) error {
+		return editorErr
+	}))
+	_, err = c.Get(ctx, "/users", nil)
+	require.True(t, errors.Is(err, editorErr))
+	require.Len(t, m.Requests(), 1)
+}
+
+func TestClient_SendCancelable(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	req := &Request{Method: http.MethodGet, URL: srv.URL}
+	ftr, cancel := c.SendCancelable(req)
+	cancel()
+
+	_, err := ftr.Response()
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Nil(t, req.Ctx)
+}
+
+func TestClient_Send_cancel(t *testing.T) {
+	started := make(chan struct{})
+	aborted := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(aborted)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ftr := c.Send(&Request{Ctx: ctx, Method: http.MethodGet, URL: srv.URL})
+
+	// Cancel once the request is blocked waiting for the response
+	<-started
+	cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := ftr.Response()
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		require.True(t, errors.Is(err, context.Canceled))
+	case <-time.After(time.Second):
+		t.Fatal("future did not return after the context was cancelled")
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("request was not aborted")
+	}
+}
+
+func TestClient_DoRequest(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users").
+		Respond(http.StatusOK, `{"result": "ok"}`).
+		Header("Content-Type", "application/json")
+	m.On(http.MethodGet, "/missing").Respond(http.StatusNotFound, "")
+
+	var hooked []int
+	c := NewFromBaseClient(m,
+		WithBaseURL("http://api.example.com"),
+		WithBearerToken("ignored"),
+		WithResponseHook(func(rsp *http.Response) error {
+			hooked = append(hooked, rsp.StatusCode)
+			return nil
+		}),
+	)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Signature", "abc")
+
+	rsp, err := c.DoRequest(req)
+	require.NoError(t, err)
+	var v testResult
+	require.NoError(t, rsp.Decode(&v))
+	require.Equal(t, "ok", v.Result)
+	require.Equal(t, "http://example.com/users", rsp.RequestURL())
+
+	// The request is sent as-is
+	sent := m.Requests()[0]
+	require.Equal(t, "abc", sent.Header.Get("X-Signature"))
+	require.Equal(t, "", sent.Header.Get("Authorization"))
+
+	// Responses are validated
+	req, err = http.NewRequest(http.MethodGet, "http://example.com/missing", nil)
+	require.NoError(t, err)
+	rsp, err = c.DoRequest(req)
+	require.Equal(t, BadStatusError(http.StatusNotFound), err)
+	require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+	require.Equal(t, []int{http.StatusOK, http.StatusNotFound}, hooked)
+}
+
+func TestClient_h2c(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.Proto))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h2c.NewHandler(h, &http2.Server{}))
+	defer srv.Close()
+	c := New(WithH2C())
+
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/2.0", rspBody)
+}
+
+func TestClient_BuildRequest(t *testing.T) {
+	c := New(WithBaseURL("http://example.com/api/"), WithBearerToken("abc"))
+
+	req, err := c.BuildRequest(&Request{
+		Method:     http.MethodPost,
+		URL:        "users/{id}",
+		PathParams: map[string]string{"id": "5"},
+		Body:       map[string]string{"foo": "bar"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "http://example.com/api/users/5", req.URL.String())
+	require.Equal(t, "application/json; charset=utf-8", req.Header.Get("Content-Type"))
+	require.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+
+	b, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"foo":"bar"}`, string(b))
+}
+
+func TestClient_headerFunc(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.Header.Get("X-Request-Path")))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithHeaderFunc("X-Request-Path", func(r *Request) (string, error) {
+		if r.URL == "" {
+			return "", errors.New("no URL")
+		}
+		return r.Method + " " + r.URL, nil
+	}))
+
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "GET "+srv.URL, rspBody)
+
+	// Headers set on the request take precedence
+	rsp, err = c.Send(&Request{
+		Method:  http.MethodGet,
+		URL:     srv.URL,
+		Headers: http.Header{"X-Request-Path": {"explicit"}},
+	}).Response()
+	require.NoError(t, err)
+	rspBody, err = rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "explicit", rspBody)
+
+	// Errors abort the request
+	_, err = c.BuildRequest(&Request{Method: http.MethodGet})
+	require.Error(t, err)
+}
+
+func TestClient_queryWithBody(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(fmt.Sprintf("%s %s %s", r.Method, r.RequestURI, b)))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithBaseURL(srv.URL))
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		query   interface{}
+		body    interface{}
+		encoder Encoder
+		want    string
+	}{
+		{
+			name:   "GET with query",
+			method: http.MethodGet,
+			url:    "/search",
+			query:  map[string]string{"q": "foo bar"},
+			want:   "GET /search?q=foo+bar ",
+		},
+		{
+			name:   "POST with query and JSON body",
+			method: http.MethodPost,
+			url:    "/search",
+			query: struct {
+				Page int `url:"page"`
+			}{2},
+			body: map[string]string{"q": "foo"},
+			want: `POST /search?page=2 {"q":"foo"}`,
+		},
+		{
+			name:    "PUT with query and form body",
+			method:  http.MethodPut,
+			url:     "/search",
+			query:   url.Values{"page": {"2"}},
+			body:    map[string]string{"q": "foo"},
+			encoder: &EncoderFormURL{},
+			want:    "PUT /search?page=2 q=foo",
+		},
+		{
+			name:   "query merged with URL",
+			method: http.MethodPost,
+			url:    "/search?page=1&sort=asc",
+			query:  map[string]string{"page": "2"},
+			want:   "POST /search?page=2&sort=asc ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := c.Send(&Request{
+				Method:  tt.method,
+				URL:     tt.url,
+				Query:   tt.query,
+				Body:    tt.body,
+				Encoder: tt.encoder,
+			}).Response()
+			require.NoError(t, err)
+			rspBody, err := rsp.BodyString()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, rspBody)
+		})
+	}
+}
+
+func TestClient_responseHeaderTimeout(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := New(WithResponseHeaderTimeout(20 * time.Millisecond))
+
+	_, err := c.Get(context.Background(), srv.URL, nil)
+	require.Error(t, err)
+}
+
+func TestClient_strictJSON(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"foo": "bar", "unknown": true}`))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithDecoder("application/json", &DecoderJSON{DisallowUnknownFields: true}))
+
+	v := &struct {
+		Foo string `json:"foo"`
+	}{}
+	rsp, err := c.Get(context.Background(), srv.URL, v)
+	require.Error(t, err)
+
+	// DecodeJSON also uses the registered decoder
+	require.Error(t, rsp.DecodeJSON(v))
+}
+
+func TestClient_conditionalGet(t *testing.T) {
+	lastModified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` || r.Header.Get("If-Modified-Since") == lastModified.Format(http.TimeFormat) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{"foo": "bar"}`))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithStatusValidator(func(status int) bool {
+		return status == http.StatusOK || status == http.StatusNotModified
+	}))
+
+	for _, set := range []func(r *Request){
+		func(r *Request) { r.SetIfNoneMatch(`"v1"`) },
+		func(r *Request) { r.SetIfModifiedSince(lastModified.In(time.FixedZone("X", 3600))) },
+	} {
+		req := &Request{Method: http.MethodGet, URL: srv.URL}
+		set(req)
+
+		rsp, err := c.Send(req).Response()
+		require.NoError(t, err)
+		require.True(t, rsp.NotModified())
+
+		v := struct {
+			Foo string `json:"foo"`
+		}{Foo: "cached"}
+		require.NoError(t, rsp.Decode(&v))
+		require.Equal(t, "cached", v.Foo)
+	}
+}
+
+// statefulEncoder reuses a buffer so it is not safe for concurrent use
+type statefulEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *statefulEncoder) ContentType() string { return "text/plain" }
+
+func (e *statefulEncoder) Encode(body interface{}) (io.Reader, error) {
+	e.buf.Reset()
+	e.buf.WriteString(body.(string))
+	return bytes.NewReader(e.buf.Bytes()), nil
+}
+
+func (e *statefulEncoder) NewEncoder() Encoder {
+	return &statefulEncoder{}
+}
+
+func TestClient_concurrent(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, err := io.Copy(w, r.Body)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for _, enc := range []Encoder{&EncoderJSON{}, &statefulEncoder{}} {
+		c := NewFromBaseClient(srv.Client(), WithEncoder(enc))
+
+		futures := make([]*Future, 50)
+		for i := range futures {
+			futures[i] = c.Send(&Request{
+				Method: http.MethodPost,
+				URL:    srv.URL,
+				Body:   fmt.Sprintf("request %d", i),
+			})
+		}
+
+		for i, ftr := range futures {
+			rsp, err := ftr.Response()
+			require.NoError(t, err)
+
+			rspBody, err := rsp.BodyString()
+			require.NoError(t, err)
+			require.Contains(t, rspBody, fmt.Sprintf("request %d", i))
+		}
+	}
+}
+
+func TestGenericHelpers(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			_, err := io.Copy(w, r.Body)
+			require.NoError(t, err)
+			return
+		}
+		_, err := w.Write([]byte(`{"foo": "bar"}`))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	type body struct {
+		Foo string `json:"foo"`
+	}
+
+	v, rsp, err := Get[body](context.Background(), c, srv.URL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Equal(t, body{Foo: "bar"}, v)
+
+	m, _, err := Post[map[string]string](context.Background(), c, srv.URL, &body{Foo: "bat"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"foo": "bat"}, m)
+}
+
+func TestResponse_urls(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/old" {
+			http.Redirect(w, r, "/api/new", http.StatusFound)
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithBaseURL(srv.URL+"/api/"))
+
+	rsp, err := c.Get(context.Background(), "old", nil)
+	require.NoError(t, err)
+	require.Equal(t, srv.URL+"/api/old", rsp.RequestURL())
+	require.Equal(t, srv.URL+"/api/new", rsp.FinalURL())
+}
+
+func TestClient_trailers(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		w.Header().Set("Trailer", "X-Echo")
+		_, err = w.Write(b)
+		require.NoError(t, err)
+		w.Header().Set("X-Echo", r.Trailer.Get("X-Checksum"))
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	rsp, err := c.Send(&Request{
+		Method:   http.MethodPost,
+		URL:      srv.URL,
+		Body:     "hello",
+		Encoder:  &EncoderText{},
+		Trailers: http.Header{"X-Checksum": {"abc123"}},
+	}).Response()
+	require.NoError(t, err)
+
+	trailers, err := rsp.Trailers()
+	require.NoError(t, err)
+	require.Equal(t, "abc123", trailers.Get("X-Echo"))
+
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "hello", rspBody)
+}
+
+func TestClient_skipStatusValidation(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	_, err := c.Send(&Request{Method: http.MethodGet, URL: srv.URL}).Response()
+	require.Error(t, err)
+
+	rsp, err := c.Send(&Request{
+		Method:               http.MethodGet,
+		URL:                  srv.URL,
+		SkipStatusValidation: true,
+	}).Response()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+}
+
+// streamingEncoder returns a reader whose length is unknown to net/http
+type streamingEncoder struct{}
+
+func (e *streamingEncoder) ContentType() string { return "text/plain" }
+
+func (e *streamingEncoder) Encode(body interface{}) (io.Reader, error) {
+	return io.MultiReader(strings.NewReader(body.(string))), nil
+}
+
+func TestClient_encodedContentLength(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(fmt.Sprintf("%d %v", r.ContentLength, r.TransferEncoding)))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithEncoder(&streamingEncoder{}))
+
+	rsp, err := c.Post(context.Background(), srv.URL, "hello", nil)
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "5 []", rspBody)
+}
+
+func TestClient_insecureSkipVerify(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	srv := httptest.NewTLSServer(h)
+	defer srv.Close()
+
+	_, err := New().Get(context.Background(), srv.URL, nil)
+	require.Error(t, err)
+
+	_, err = New(WithInsecureSkipVerify()).Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+}
+
+func TestClient_bodyFunc(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusTemporaryRedirect)
+			return
+		}
+		_, err := io.Copy(w, r.Body)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	calls := 0
+	body := func() (io.Reader, error) {
+		calls++
+		return io.MultiReader(strings.NewReader(fmt.Sprintf("attempt %d", calls))), nil
+	}
+
+	// The body is fetched again when following the redirect
+	rsp, err := c.Post(context.Background(), srv.URL+"/old", body, nil)
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "attempt 2", rspBody)
+	require.Equal(t, 2, calls)
+
+	_, err = c.Post(context.Background(), srv.URL, func() (io.Reader, error) {
+		return nil, errors.New("no body")
+	}, nil)
+	require.Error(t, err)
+}
+
+func TestClient_timeoutError(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	// The client's timeout
+	c := NewFromBaseClient(srv.Client(), WithTimeout(20*time.Millisecond))
+	_, err := c.Get(context.Background(), srv.URL, nil)
+	require.True(t, IsTimeout(err))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	var timeoutErr *TimeoutError
+	require.True(t, errors.As(err, &timeoutErr))
+
+	// The context's deadline. WithTimeout modified
+	// srv.Client(), so use a new http.Client.
+	c = NewFromBaseClient(&http.Client{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Get(ctx, srv.URL, nil)
+	require.True(t, IsTimeout(err))
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Cancellation is not a timeout
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = c.Get(ctx, srv.URL, nil)
+	require.False(t, IsTimeout(err))
+	require.True(t, errors.Is(err, context.Canceled))
+	require.False(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestClient_chunked(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(fmt.Sprintf("%d %v %s", r.ContentLength, r.TransferEncoding, b)))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	tests := []struct {
+		name string
+		body interface{}
+		want string
+	}{
+		{
+			name: "known length reader",
+			body: strings.NewReader("hello"),
+			want: "-1 [chunked] hello",
+		},
+		{
+			name: "unknown length reader",
+			body: io.MultiReader(strings.NewReader("hello")),
+			want: "-1 [chunked] hello",
+		},
+		{
+			name: "streaming encoder",
+			body: "hello",
+			want: "-1 [chunked] hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := c.Send(&Request{
+				Method:  http.MethodPost,
+				URL:     srv.URL,
+				Body:    tt.body,
+				Encoder: &streamingEncoder{},
+				Chunked: true,
+			}).Response()
+			require.NoError(t, err)
+			rspBody, err := rsp.BodyString()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, rspBody)
+		})
+	}
+}
+
+func TestClient_nilTargetLeavesBodyUnread(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users").Respond(http.StatusOK, "hello")
+	c := NewFromBaseClient(m)
+
+	rsp, err := c.Get(context.Background(), "/users", nil)
+	require.NoError(t, err)
+	require.NotEqual(t, reflect.TypeOf(&bufCloser{}), reflect.TypeOf(rsp.Body))
+
+	// The body can be streamed
+	b, err := ioutil.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+}
+
+func TestClient_autoRequestID(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users").Respond(http.StatusOK, "")
+	c := NewFromBaseClient(m, WithAutoRequestID("X-Request-ID"))
+
+	rsp, err := c.Get(context.Background(), "/users", nil)
+	require.NoError(t, err)
+	require.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, rsp.RequestID())
+	require.Equal(t, rsp.RequestID(), m.Requests()[0].Header.Get("X-Request-ID"))
+
+	// A new ID is generated for each request
+	rsp2, err := c.Get(context.Background(), "/users", nil)
+	require.NoError(t, err)
+	require.NotEqual(t, rsp.RequestID(), rsp2.RequestID())
+
+	// An ID set on the request is not overridden
+	rsp, err = c.Send(&Request{
+		Method:  http.MethodGet,
+		URL:     "/users",
+		Headers: http.Header{"X-Request-Id": {"abc"}},
+	}).Response()
+	require.NoError(t, err)
+	require.Equal(t, "abc", rsp.RequestID())
+}
+
+func TestClient_baseContext(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewFromBaseClient(srv.Client(), WithBaseContext(ctx))
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	// Requests without a context are aborted when the base context is canceled
+	start := time.Now()
+	_, err := c.Send(&Request{Method: http.MethodGet, URL: srv.URL}).Response()
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+
+	// A request's own context takes precedence
+	req, err := c.BuildRequest(&Request{Ctx: context.Background(), Method: http.MethodGet, URL: srv.URL})
+	require.NoError(t, err)
+	require.NoError(t, req.Context().Err())
+}
+
+func TestClient_truncatedBody(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		_, err = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"result\":")
+		require.NoError(t, err)
+		require.NoError(t, buf.Flush())
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	var v testResult
+	_, err := c.Get(context.Background(), srv.URL, &v)
+	require.True(t, errors.Is(err, ErrShortBody))
+}
+
+func TestClient_maxConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	release := make(chan struct{})
+
+	base := doerFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		<-release
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+
+	c := NewFromBaseClient(base, WithMaxConcurrency(2))
+
+	var ftrs []*Future
+	for i := 0; i < 5; i++ {
+		ftrs = append(ftrs, c.Send(&Request{Method: http.MethodGet, URL: "/"}))
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return inFlight == 2
+	}, time.Second, time.Millisecond)
+
+	// Waiting requests respect their context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.Get(ctx, "/", nil)
+	require.True(t, IsTimeout(err))
+
+	// A slot is freed when the response body is closed
+	close(release)
+	for _, ftr := range ftrs {
+		go func(ftr *Future) {
+			if rsp, err := ftr.Response(); err == nil {
+				_ = rsp.Body.Close()
+			}
+		}(ftr)
+	}
+	for _, ftr := range ftrs {
+		_, err := ftr.Response()
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 2, maxInFlight)
+}
+
+func TestClient_maxConcurrencyHoldsUntilBodyClosed(t *testing.T) {
+	base := doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("body")),
+		}, nil
+	})
+
+	c := NewFromBaseClient(base, WithMaxConcurrency(1))
+
+	first, err := c.Send(&Request{Method: http.MethodGet, URL: "/"}).Response()
+	require.NoError(t, err)
+
+	// The second request waits while the first body is open
+	second := c.Send(&Request{Method: http.MethodGet, URL: "/"})
+	select {
+	case <-second.done:
+		t.Fatal("second request was sent before the first body was closed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Closing the body more than once only frees one slot
+	require.NoError(t, first.Body.Close())
+	require.NoError(t, first.Body.Close())
+
+	rsp, err := second.Response()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Send(&Request{Method: http.MethodGet, URL: "/", Ctx: ctx}).Response()
+	require.True(t, IsTimeout(err))
+
+	require.NoError(t, rsp.Body.Close())
+}
+
+func TestClient_bodyTransformer(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users").
+		Respond(http.StatusOK, ")]}',\n{\"result\": \"ok\"}").
+		Header("Content-Type", "application/json")
+	m.On(http.MethodGet, "/broken").
+		Respond(http.StatusOK, "{}").
+		Header("Content-Type", "application/json")
+
+	c := NewFromBaseClient(m, WithBodyTransformer(func(b []byte) ([]byte, error) {
+		if !bytes.HasPrefix(b, []byte(")]}',\n")) {
+			return nil, errors.New("missing prefix")
+		}
+		return bytes.TrimPrefix(b, []byte(")]}',\n")), nil
+	}))
+
+	var v testResult
+	rsp, err := c.Get(context.Background(), "/users", &v)
+	require.NoError(t, err)
+	require.Equal(t, "ok", v.Result)
+
+	// BodyBytes returns the body as received
+	b, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, ")]}',\n{\"result\": \"ok\"}", b)
+
+	_, err = c.Get(context.Background(), "/broken", &v)
+	require.EqualError(t, err, "failed to transform body: missing prefix")
+}
+
+func TestClient_defaultResponseContentType(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users").Respond(http.StatusOK, `{"result": "ok"}`)
+	m.On(http.MethodGet, "/yaml").
+		Respond(http.StatusOK, "result: yaml").
+		Header("Content-Type", "application/yaml")
+
+	var v testResult
+	_, err := NewFromBaseClient(m).Get(context.Background(), "/users", &v)
+	require.Equal(t, ContentTypeError(""), err)
+
+	c := NewFromBaseClient(m, WithDefaultResponseContentType("application/json"))
+	_, err = c.Get(context.Background(), "/users", &v)
+	require.NoError(t, err)
+	require.Equal(t, "ok", v.Result)
+
+	// The header takes precedence
+	_, err = c.Get(context.Background(), "/yaml", &v)
+	require.NoError(t, err)
+	require.Equal(t, "yaml", v.Result)
+}
+
+func TestClient_methodTimeout(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithMethodTimeout(http.MethodGet, 20*time.Millisecond))
+
+	_, err := c.Get(context.Background(), srv.URL, nil)
+	require.True(t, IsTimeout(err))
+
+	// Other methods are not affected
+	_, err = c.Post(context.Background(), srv.URL, nil, nil)
+	require.NoError(t, err)
+
+	// A deadline on the request's context takes precedence
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = c.Get(ctx, srv.URL, nil)
+	require.NoError(t, err)
+}
+
+func TestClient_host(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.Host))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	rsp, err := c.Send(&Request{
+		Method: http.MethodGet,
+		URL:    srv.URL,
+		Host:   "api.example.com",
+	}).Response()
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "api.example.com", rspBody)
+}
+
+func TestClient_idempotencyKey(t *testing.T) {
+	c := New()
+
+	req, err := c.BuildRequest(&Request{
+		Method:         http.MethodPost,
+		URL:            "http://example.com",
+		IdempotencyKey: "abc",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "abc", req.Header.Get("Idempotency-Key"))
+
+	// A header set on the request takes precedence
+	req, err = c.BuildRequest(&Request{
+		Method:         http.MethodPost,
+		URL:            "http://example.com",
+		Headers:        http.Header{"Idempotency-Key": {"def"}},
+		IdempotencyKey: "abc",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "def", req.Header.Get("Idempotency-Key"))
+}
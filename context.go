@@ -0,0 +1,22 @@
+package patch
+
+import (
+	"context"
+)
+
+type operationNameKey struct{}
+
+// WithOperationName returns a copy of ctx that carries the name of a
+// logical operation, e.g. "GetUser". The context of the *http.Request
+// passed to the BaseClient is derived from the request's context, so
+// Doer middleware can read the name using OperationNameFromContext.
+func WithOperationName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, operationNameKey{}, name)
+}
+
+// OperationNameFromContext returns the operation name
+// set by WithOperationName or an empty string if none.
+func OperationNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(operationNameKey{}).(string)
+	return name
+}
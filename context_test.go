@@ -0,0 +1,30 @@
+package patch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationName(t *testing.T) {
+	require.Equal(t, "", OperationNameFromContext(context.Background()))
+
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users/1").Respond(http.StatusOK, "")
+	c := NewFromBaseClient(m)
+
+	// The name reaches the Doer through the request's context
+	ctx := WithOperationName(context.Background(), "GetUser")
+	_, err := c.Get(ctx, "/users/1", nil)
+	require.NoError(t, err)
+	require.Equal(t, "GetUser", OperationNameFromContext(m.Requests()[0].Context()))
+
+	// The name also reaches the Doer when using SendCancelable
+	ftr, cancel := c.SendCancelable(&Request{Ctx: ctx, Method: http.MethodGet, URL: "/users/1"})
+	defer cancel()
+	_, err = ftr.Response()
+	require.NoError(t, err)
+	require.Equal(t, "GetUser", OperationNameFromContext(m.Requests()[1].Context()))
+}
@@ -1,8 +1,18 @@
 package patch
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
 )
 
 // Decoder is the interface for types that can decode a response body.
@@ -11,20 +21,97 @@ type Decoder interface {
 	Decode([]byte, interface{}) error
 }
 
-var jsonDecoder = &DecoderJSON{}
+var (
+	jsonDecoder    = &DecoderJSON{}
+	yamlDecoder    = &DecoderYAML{}
+	textDecoder    = &DecoderText{}
+	msgpackDecoder = &DecoderMsgpack{}
+	protoDecoder   = &DecoderProto{}
+)
+
+// builtinDecoders maps media types to the decoders that are
+// used if no decoder has been registered on the client.
+var builtinDecoders = map[string]Decoder{
+	"application/json":       jsonDecoder,
+	"application/yaml":       yamlDecoder,
+	"text/yaml":              yamlDecoder,
+	"text/plain":             textDecoder,
+	"application/msgpack":    msgpackDecoder,
+	"application/x-msgpack":  msgpackDecoder,
+	"application/protobuf":   protoDecoder,
+	"application/x-protobuf": protoDecoder,
+}
+
+// inferDecoder returns the decoder for the given Content-Type. Decoders
+// in the custom map take precedence over the built-in decoders.
+func inferDecoder(contentType string, custom map[string]Decoder) (Decoder, error) {
+	mt := mediaType(contentType)
 
-func inferDecoder(contentType string) (Decoder, error) {
-	switch contentType {
-	case "application/json",
-		"application/json; charset=utf-8":
-		return jsonDecoder, nil
+	if dec, ok := custom[mt]; ok {
+		return dec, nil
+	}
+
+	if dec, ok := builtinDecoders[mt]; ok {
+		return dec, nil
 	}
 
 	return nil, ContentTypeError(contentType)
 }
 
-// DecoderJSON decodes JSON bodies
-type DecoderJSON struct{}
+// toUTF8 transcodes data to UTF-8 according to the charset
+// parameter of the Content-Type. If there is no charset
+// parameter, the data is assumed to be UTF-8 already.
+func toUTF8(data []byte, contentType string) ([]byte, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return data, nil
+	}
+
+	charset := strings.ToLower(params["charset"])
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii":
+		return data, nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+
+	if enc == unicode.UTF8 {
+		return data, nil
+	}
+
+	b, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode body from %s: %w", charset, err)
+	}
+
+	return b, nil
+}
+
+// mediaType returns the lower-case media type of a Content-Type
+// header value, with any parameters such as charset removed.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mt = strings.Split(contentType, ";")[0]
+	}
+
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// DecoderJSON decodes JSON bodies. The zero value is lenient. To
+// use a stricter decoder, register one using WithDecoder.
+type DecoderJSON struct {
+	// DisallowUnknownFields causes an error to be returned if
+	// the body contains fields that are not in the target struct.
+	DisallowUnknownFields bool
+
+	// UseNumber decodes numbers into an interface{}
+	// as a json.Number instead of a float64.
+	UseNumber bool
+}
 
 // Name returns the name of the format
 func (d *DecoderJSON) Name() string {
@@ -33,9 +120,108 @@ func (d *DecoderJSON) Name() string {
 
 // Decode unmarshals a JSON response body
 func (d *DecoderJSON) Decode(data []byte, v interface{}) error {
-	if err := json.Unmarshal(data, v); err != nil {
+	if !d.DisallowUnknownFields && !d.UseNumber {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to decode body as JSON: %w", err)
+		}
+
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if d.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if d.UseNumber {
+		dec.UseNumber()
+	}
+
+	if err := dec.Decode(v); err != nil {
 		return fmt.Errorf("failed to decode body as JSON: %w", err)
 	}
 
+	// Match json.Unmarshal by rejecting data after the value
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("failed to decode body as JSON: unexpected data after top-level value")
+	}
+
+	return nil
+}
+
+// DecoderYAML decodes YAML bodies
+type DecoderYAML struct{}
+
+// Name returns the name of the format
+func (d *DecoderYAML) Name() string {
+	return "YAML"
+}
+
+// Decode unmarshals a YAML response body
+func (d *DecoderYAML) Decode(data []byte, v interface{}) error {
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode body as YAML: %w", err)
+	}
+
+	return nil
+}
+
+// DecoderMsgpack decodes MessagePack bodies
+type DecoderMsgpack struct{}
+
+// Name returns the name of the format
+func (d *DecoderMsgpack) Name() string {
+	return "MessagePack"
+}
+
+// Decode unmarshals a MessagePack response body
+func (d *DecoderMsgpack) Decode(data []byte, v interface{}) error {
+	if err := msgpack.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode body as MessagePack: %w", err)
+	}
+
+	return nil
+}
+
+// DecoderProto decodes protobuf bodies into proto.Message targets
+type DecoderProto struct{}
+
+// Name returns the name of the format
+func (d *DecoderProto) Name() string {
+	return "protobuf"
+}
+
+// Decode unmarshals a protobuf response body
+func (d *DecoderProto) Decode(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("failed to decode body as protobuf: %T does not implement proto.Message", v)
+	}
+
+	if err := proto.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("failed to decode body as protobuf: %w", err)
+	}
+
+	return nil
+}
+
+// DecoderText copies the raw body into *string and *[]byte targets
+type DecoderText struct{}
+
+// Name returns the name of the format
+func (d *DecoderText) Name() string {
+	return "text"
+}
+
+// Decode assigns the response body to the target
+func (d *DecoderText) Decode(data []byte, v interface{}) error {
+	switch t := v.(type) {
+	case *string:
+		*t = string(data)
+	case *[]byte:
+		*t = append([]byte(nil), data...)
+	default:
+		return fmt.Errorf("failed to decode body as text: unsupported target type %T", v)
+	}
+
 	return nil
 }
@@ -0,0 +1,225 @@
+package patch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testDecoder struct{}
+
+func (d *testDecoder) Name() string                     { return "test" }
+func (d *testDecoder) Decode([]byte, interface{}) error { return nil }
+
+func TestInferDecoder(t *testing.T) {
+	custom := &testDecoder{}
+
+	tests := []struct {
+		name        string
+		contentType string
+		decoders    map[string]Decoder
+		want        Decoder
+		wantErr     bool
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			want:        jsonDecoder,
+		},
+		{
+			name:        "json with charset",
+			contentType: "application/json; charset=utf-8",
+			want:        jsonDecoder,
+		},
+		{
+			name:        "json mixed case",
+			contentType: "Application/JSON; Charset=UTF-8",
+			want:        jsonDecoder,
+		},
+		{
+			name:        "yaml",
+			contentType: "application/yaml",
+			want:        yamlDecoder,
+		},
+		{
+			name:        "text yaml",
+			contentType: "text/yaml; charset=utf-8",
+			want:        yamlDecoder,
+		},
+		{
+			name:        "text",
+			contentType: "text/plain; charset=utf-8",
+			want:        textDecoder,
+		},
+		{
+			name:        "msgpack",
+			contentType: "application/msgpack",
+			want:        msgpackDecoder,
+		},
+		{
+			name:        "protobuf",
+			contentType: "application/x-protobuf",
+			want:        protoDecoder,
+		},
+		{
+			name:        "custom",
+			contentType: "application/xml; charset=utf-8",
+			decoders:    map[string]Decoder{"application/xml": custom},
+			want:        custom,
+		},
+		{
+			name:        "custom overrides built-in",
+			contentType: "application/json",
+			decoders:    map[string]Decoder{"application/json": custom},
+			want:        custom,
+		},
+		{
+			name:        "unknown",
+			contentType: "application/xml",
+			wantErr:     true,
+		},
+		{
+			name:        "empty",
+			contentType: "",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inferDecoder(tt.contentType, tt.decoders)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("inferDecoder() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecoderJSON(t *testing.T) {
+	type target struct {
+		Foo string `json:"foo"`
+	}
+
+	tests := []struct {
+		name    string
+		dec     *DecoderJSON
+		data    string
+		v       interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "lenient",
+			dec:  &DecoderJSON{},
+			data: `{"foo": "bar", "bat": 1}`,
+			v:    &target{},
+			want: &target{Foo: "bar"},
+		},
+		{
+			name:    "disallow unknown fields",
+			dec:     &DecoderJSON{DisallowUnknownFields: true},
+			data:    `{"foo": "bar", "bat": 1}`,
+			v:       &target{},
+			wantErr: true,
+		},
+		{
+			name: "disallow unknown fields with known fields",
+			dec:  &DecoderJSON{DisallowUnknownFields: true},
+			data: `{"foo": "bar"} `,
+			v:    &target{},
+			want: &target{Foo: "bar"},
+		},
+		{
+			name: "float",
+			dec:  &DecoderJSON{},
+			data: `{"n": 12345678901234567890}`,
+			v:    &map[string]interface{}{},
+			want: &map[string]interface{}{"n": 12345678901234567890.0},
+		},
+		{
+			name: "use number",
+			dec:  &DecoderJSON{UseNumber: true},
+			data: `{"n": 12345678901234567890}`,
+			v:    &map[string]interface{}{},
+			want: &map[string]interface{}{"n": json.Number("12345678901234567890")},
+		},
+		{
+			name:    "trailing data",
+			dec:     &DecoderJSON{UseNumber: true},
+			data:    `{"foo": "bar"} {}`,
+			v:       &target{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.dec.Decode([]byte(tt.data), tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				require.Equal(t, tt.want, tt.v)
+			}
+		})
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "no charset",
+			data:        []byte("caf\xc3\xa9"),
+			contentType: "text/plain",
+			want:        "café",
+		},
+		{
+			name:        "utf-8",
+			data:        []byte("caf\xc3\xa9"),
+			contentType: "application/json; charset=UTF-8",
+			want:        "café",
+		},
+		{
+			name:        "iso-8859-1",
+			data:        []byte("caf\xe9"),
+			contentType: "text/xml; charset=ISO-8859-1",
+			want:        "café",
+		},
+		{
+			name:        "windows-1252",
+			data:        []byte("\x80"),
+			contentType: "text/plain; charset=windows-1252",
+			want:        "€",
+		},
+		{
+			name:        "unknown charset",
+			data:        []byte("foo"),
+			contentType: "text/plain; charset=nope",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toUTF8(tt.data, tt.contentType)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("toUTF8() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
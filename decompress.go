@@ -0,0 +1,142 @@
+package patch
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decompressor returns a reader that decompresses r. It
+// is registered for a Content-Encoding such as "gzip".
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+// builtinDecompressors are used if the client does
+// not have a decompressor registered for the encoding
+var builtinDecompressors = map[string]Decompressor{
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	// The deflate content coding is zlib, not raw DEFLATE
+	"deflate": func(r io.Reader) (io.ReadCloser, error) {
+		return zlib.NewReader(r)
+	},
+	"br": func(r io.Reader) (io.ReadCloser, error) {
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	},
+	"zstd": func(r io.Reader) (io.ReadCloser, error) {
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	},
+}
+
+// acceptEncoding returns the content codings that the client can decompress
+func (c *Client) acceptEncoding() string {
+	seen := make(map[string]bool)
+	var encodings []string
+
+	for _, m := range []map[string]Decompressor{c.decompressors, builtinDecompressors} {
+		for enc := range m {
+			if !seen[enc] {
+				seen[enc] = true
+				encodings = append(encodings, enc)
+			}
+		}
+	}
+
+	sort.Strings(encodings)
+	return strings.Join(encodings, ", ")
+}
+
+// decompress replaces the body of an encoded response with a reader
+// that decompresses it, and removes the headers that describe the
+// compressed body, as the http.Transport does. Responses with an
+// encoding that has no decompressor are left untouched.
+func decompress(rsp *http.Response, custom map[string]Decompressor) {
+	var decs []Decompressor
+	for _, v := range rsp.Header.Values("Content-Encoding") {
+		for _, enc := range strings.Split(v, ",") {
+			enc = strings.ToLower(strings.TrimSpace(enc))
+			if enc == "" || enc == "identity" {
+				continue
+			}
+
+			dec, ok := custom[enc]
+			if !ok {
+				dec, ok = builtinDecompressors[enc]
+			}
+			if !ok {
+				return
+			}
+
+			decs = append(decs, dec)
+		}
+	}
+
+	if len(decs) == 0 {
+		return
+	}
+
+	rsp.Body = &decompressBody{body: rsp.Body, decs: decs}
+	rsp.Header.Del("Content-Encoding")
+	rsp.Header.Del("Content-Length")
+	rsp.ContentLength = -1
+	rsp.Uncompressed = true
+}
+
+// decompressBody decompresses the body lazily, so
+// that an empty body can be closed without error
+type decompressBody struct {
+	body io.ReadCloser
+
+	// decs are in the order that the encodings were applied
+	decs []Decompressor
+
+	r       io.Reader
+	closers []io.Closer
+	err     error
+}
+
+// Read reads decompressed data from the body
+func (d *decompressBody) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	if d.r == nil {
+		// Undo the encodings in the reverse order to which they were applied
+		var r io.Reader = d.body
+		for i := len(d.decs) - 1; i >= 0; i-- {
+			rc, err := d.decs[i](r)
+			if err != nil {
+				d.err = err
+				return 0, err
+			}
+
+			d.closers = append(d.closers, rc)
+			r = rc
+		}
+
+		d.r = r
+	}
+
+	return d.r.Read(p)
+}
+
+// Close closes the decompressors and the underlying body
+func (d *decompressBody) Close() error {
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		_ = d.closers[i].Close()
+	}
+
+	return d.body.Close()
+}
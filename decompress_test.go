@@ -0,0 +1,139 @@
+package patch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_transparentGzip(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			_, err := w.Write([]byte("hello"))
+			require.NoError(t, err)
+			return
+		}
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write([]byte("hello"))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		w.Header().Set("Content-Encoding", "gzip")
+		_, err = w.Write(buf.Bytes())
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(), WithTransparentGzip(true))
+
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	require.Equal(t, "br, deflate, gzip, zstd", rsp.Header.Get("X-Accept-Encoding"))
+	require.Equal(t, "", rsp.Header.Get("Content-Encoding"))
+	require.Equal(t, int64(-1), rsp.ContentLength)
+	require.True(t, rsp.Uncompressed)
+	b, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "hello", b)
+
+	// An Accept-Encoding header set on the request is kept
+	rsp, err = c.Send(&Request{
+		Method:  http.MethodGet,
+		URL:     srv.URL,
+		Headers: http.Header{"Accept-Encoding": {"identity"}},
+	}).Response()
+	require.NoError(t, err)
+	require.Equal(t, "identity", rsp.Header.Get("X-Accept-Encoding"))
+	b, err = rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "hello", b)
+
+	// HEAD responses have no body to decompress
+	rsp, err = c.Head(context.Background(), srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, rsp.Body.Close())
+}
+
+func TestClient_decompress(t *testing.T) {
+	compress := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"br":      func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+		"zstd": func(w io.Writer) io.WriteCloser {
+			zw, err := zstd.NewWriter(w)
+			require.NoError(t, err)
+			return zw
+		},
+		"b64": func(w io.Writer) io.WriteCloser {
+			return base64.NewEncoder(base64.StdEncoding, w)
+		},
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := []byte("hello")
+		encodings := strings.Split(r.URL.Query().Get("encoding"), ",")
+		for _, enc := range encodings {
+			var buf bytes.Buffer
+			cw := compress[enc](&buf)
+			_, err := cw.Write(body)
+			require.NoError(t, err)
+			require.NoError(t, cw.Close())
+			body = buf.Bytes()
+		}
+
+		w.Header().Set("Content-Encoding", strings.Join(encodings, ", "))
+		_, err := w.Write(body)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client(),
+		WithTransparentGzip(true),
+		WithDecompressor("B64", func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+		}),
+	)
+
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd", "b64", "gzip,b64"} {
+		t.Run(encoding, func(t *testing.T) {
+			rsp, err := c.Get(context.Background(), srv.URL+"?encoding="+encoding, nil)
+			require.NoError(t, err)
+			require.Equal(t, "", rsp.Header.Get("Content-Encoding"))
+			b, err := rsp.BodyString()
+			require.NoError(t, err)
+			require.Equal(t, "hello", b)
+		})
+	}
+
+	// Unknown encodings are left untouched
+	rsp := &http.Response{
+		Header: http.Header{"Content-Encoding": {"gzip, compress"}},
+		Body:   ioutil.NopCloser(strings.NewReader("compressed")),
+	}
+	decompress(rsp, nil)
+	require.Equal(t, "gzip, compress", rsp.Header.Get("Content-Encoding"))
+	b, err := ioutil.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "compressed", string(b))
+
+	req, err := c.BuildRequest(&Request{Method: http.MethodGet, URL: srv.URL})
+	require.NoError(t, err)
+	require.Equal(t, "b64, br, deflate, gzip, zstd", req.Header.Get("Accept-Encoding"))
+}
@@ -0,0 +1,124 @@
+package patch
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Deduplicator is a Doer that coalesces identical GET and HEAD requests
+// that are in flight at the same time into a single call to the next
+// Doer. Requests are identical if they have the same method and URL;
+// headers are ignored, so don't share a Deduplicator between callers
+// whose credentials give them different views of a resource. The
+// response body is read into memory and each caller gets its own
+// copy of the response. If the first caller's request is cancelled,
+// all callers waiting on it receive the error.
+type Deduplicator struct {
+	// Next is the Doer that requests are passed to
+	Next Doer
+
+	// MaxBodyBytes limits the size of the response body that is read
+	// into memory. If it is exceeded, every caller waiting on the call
+	// receives ErrResponseTooLarge. Zero means no limit.
+	MaxBodyBytes int64
+
+	mu    sync.Mutex
+	calls map[string]*dedupeCall
+}
+
+// NewDeduplicator returns a Deduplicator that wraps next
+func NewDeduplicator(next Doer) *Deduplicator {
+	return &Deduplicator{Next: next}
+}
+
+// dedupeCall is a request that is in flight
+type dedupeCall struct {
+	done chan struct{}
+	rsp  *http.Response
+	body []byte
+	err  error
+}
+
+// Do passes the request to the next Doer or, if an identical
+// request is already in flight, waits for its response
+func (d *Deduplicator) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return d.Next.Do(req)
+	}
+
+	key := req.Method + " " + req.URL.String()
+
+	d.mu.Lock()
+	if d.calls == nil {
+		d.calls = make(map[string]*dedupeCall)
+	}
+
+	if c, ok := d.calls[key]; ok {
+		d.mu.Unlock()
+
+		select {
+		case <-c.done:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		return c.response(req, true)
+	}
+
+	c := &dedupeCall{done: make(chan struct{})}
+	d.calls[key] = c
+	d.mu.Unlock()
+
+	c.rsp, c.err = d.Next.Do(req)
+	if c.err == nil {
+		c.body, c.err = d.readBody(c.rsp.Body)
+		_ = c.rsp.Body.Close()
+	}
+
+	d.mu.Lock()
+	delete(d.calls, key)
+	d.mu.Unlock()
+	close(c.done)
+
+	return c.response(req, false)
+}
+
+// readBody reads the body into memory, up to MaxBodyBytes
+func (d *Deduplicator) readBody(body io.Reader) ([]byte, error) {
+	if d.MaxBodyBytes <= 0 {
+		return ioutil.ReadAll(body)
+	}
+
+	// Read at most one byte more than the limit
+	// so that we know if the limit was exceeded.
+	b, err := ioutil.ReadAll(io.LimitReader(body, d.MaxBodyBytes+1))
+	if err == nil && int64(len(b)) > d.MaxBodyBytes {
+		return nil, ErrResponseTooLarge
+	}
+
+	return b, err
+}
+
+// response returns a copy of the shared response with a buffered
+// body, for the caller that sent req. If copyBody is false, the
+// body is not copied, which is how the first caller gets it.
+func (c *dedupeCall) response(req *http.Request, copyBody bool) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	body := c.body
+	if copyBody {
+		body = append([]byte(nil), c.body...)
+	}
+
+	rsp := *c.rsp
+	rsp.Header = c.rsp.Header.Clone()
+	rsp.Trailer = c.rsp.Trailer.Clone()
+	rsp.Body = newBufCloser(body)
+	rsp.Request = req
+
+	return &rsp, nil
+}
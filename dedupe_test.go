@@ -0,0 +1,153 @@
+package patch
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicator(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	d := NewDeduplicator(doerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		if req.Method == http.MethodGet {
+			<-release
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       ioutil.NopCloser(strings.NewReader("body")),
+		}, nil
+	}))
+
+	const n = 5
+	var wg sync.WaitGroup
+	var entered int32
+	bodies := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+			require.NoError(t, err)
+			atomic.AddInt32(&entered, 1)
+			rsp, err := d.Do(req)
+			require.NoError(t, err)
+			require.Same(t, req, rsp.Request)
+			b, err := ioutil.ReadAll(rsp.Body)
+			require.NoError(t, err)
+			bodies[i] = string(b)
+		}(i)
+	}
+
+	// Wait for all of the requests to reach the Deduplicator
+	// while the first one is blocked in the next Doer
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&entered) == n && atomic.LoadInt32(&calls) == 1
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, b := range bodies {
+		require.Equal(t, "body", b)
+	}
+
+	// A later request makes a new call
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	require.NoError(t, err)
+	_, err = d.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Other methods are not coalesced
+	req, err = http.NewRequest(http.MethodPost, "http://example.com/users", nil)
+	require.NoError(t, err)
+	_, err = d.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestDeduplicator_cancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	d := NewDeduplicator(doerFunc(func(req *http.Request) (*http.Response, error) {
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	go func() { _, _ = d.Do(req) }()
+
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	// A waiting request can be cancelled without affecting the call
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.Do(req.WithContext(ctx))
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestDeduplicator_maxBodyBytes(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	d := NewDeduplicator(doerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("too long")),
+		}, nil
+	}))
+	d.MaxBodyBytes = 4
+
+	const n = 3
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+			require.NoError(t, err)
+			_, errs[i] = d.Do(req)
+		}(i)
+	}
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) == 1
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	for _, err := range errs {
+		require.Equal(t, ErrResponseTooLarge, err)
+	}
+
+	// A body within the limit is returned
+	d.MaxBodyBytes = 8
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	require.NoError(t, err)
+	rsp, err := d.Do(req)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(rsp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "too long", string(b))
+}
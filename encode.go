@@ -6,14 +6,28 @@ import (
 	"fmt"
 	"io"
 	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
 )
 
 // Encoder is the interface for types that can encode a request body.
+// A client's encoder is shared by all of its requests, so it must be
+// safe for concurrent use. Encoders that hold state should implement
+// EncoderFactory instead.
 type Encoder interface {
 	ContentType() string
 	Encode(interface{}) (io.Reader, error)
 }
 
+// EncoderFactory is an optional interface for Encoders that are not
+// safe for concurrent use. If a request's encoder implements it,
+// NewEncoder is called to obtain a fresh Encoder for each request.
+type EncoderFactory interface {
+	NewEncoder() Encoder
+}
+
 // EncoderJSON encodes bodies as JSON
 type EncoderJSON struct{
 	// CustomContentType overrides the default ContentType
@@ -40,6 +54,117 @@ func (e EncoderJSON) Encode(body interface{}) (io.Reader, error) {
 	return bytes.NewReader(b), nil
 }
 
+// EncoderYAML encodes bodies as YAML
+type EncoderYAML struct {
+	// CustomContentType overrides the default ContentType
+	// of application/yaml
+	CustomContentType string
+}
+
+// ContentType returns the ContentType header to set in an outbound request
+func (e EncoderYAML) ContentType() string {
+	if e.CustomContentType != "" {
+		return e.CustomContentType
+	}
+
+	return "application/yaml"
+}
+
+// Encode marshals an arbitrary data structure into YAML
+func (e EncoderYAML) Encode(body interface{}) (io.Reader, error) {
+	b, err := yaml.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode body as YAML: %w", err)
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// EncoderMsgpack encodes bodies as MessagePack
+type EncoderMsgpack struct {
+	// CustomContentType overrides the default ContentType
+	// of application/msgpack
+	CustomContentType string
+}
+
+// ContentType returns the ContentType header to set in an outbound request
+func (e EncoderMsgpack) ContentType() string {
+	if e.CustomContentType != "" {
+		return e.CustomContentType
+	}
+
+	return "application/msgpack"
+}
+
+// Encode marshals an arbitrary data structure into MessagePack
+func (e EncoderMsgpack) Encode(body interface{}) (io.Reader, error) {
+	b, err := msgpack.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode body as MessagePack: %w", err)
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// EncoderProto encodes proto.Message bodies as protobuf
+type EncoderProto struct {
+	// CustomContentType overrides the default ContentType
+	// of application/x-protobuf
+	CustomContentType string
+}
+
+// ContentType returns the ContentType header to set in an outbound request
+func (e EncoderProto) ContentType() string {
+	if e.CustomContentType != "" {
+		return e.CustomContentType
+	}
+
+	return "application/x-protobuf"
+}
+
+// Encode marshals a proto.Message into the protobuf wire format
+func (e EncoderProto) Encode(body interface{}) (io.Reader, error) {
+	m, ok := body.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("failed to encode body as protobuf: %T does not implement proto.Message", body)
+	}
+
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode body as protobuf: %w", err)
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// EncoderText sends string and []byte bodies without transformation
+type EncoderText struct {
+	// CustomContentType overrides the default ContentType
+	// of text/plain
+	CustomContentType string
+}
+
+// ContentType returns the ContentType header to set in an outbound request
+func (e EncoderText) ContentType() string {
+	if e.CustomContentType != "" {
+		return e.CustomContentType
+	}
+
+	return "text/plain"
+}
+
+// Encode returns a reader over a string or []byte body
+func (e EncoderText) Encode(body interface{}) (io.Reader, error) {
+	switch v := body.(type) {
+	case string:
+		return strings.NewReader(v), nil
+	case []byte:
+		return bytes.NewReader(v), nil
+	}
+
+	return nil, fmt.Errorf("failed to encode body as text: unsupported type %T", body)
+}
+
 // EncoderFormURL encodes bodies as x-www-form-urlencoded
 type EncoderFormURL struct {
 	// CustomContentType overrides the default ContentType
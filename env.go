@@ -0,0 +1,44 @@
+package patch
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NewFromEnv returns a new Client configured from environment variables
+// with the given prefix. The following variables are supported:
+//
+//	<PREFIX>_BASE_URL            the client's BaseURL
+//	<PREFIX>_TIMEOUT             the timeout, e.g. "10s"
+//	<PREFIX>_MAX_RESPONSE_BYTES  the client's MaxResponseBytes
+//
+// Options derived from the environment are applied before opts, so opts
+// take precedence. The standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// variables are respected by the default transport.
+func NewFromEnv(prefix string, opts ...Option) (*Client, error) {
+	var envOpts []Option
+
+	if v, ok := os.LookupEnv(prefix + "_BASE_URL"); ok {
+		envOpts = append(envOpts, WithBaseURL(v))
+	}
+
+	if v, ok := os.LookupEnv(prefix + "_TIMEOUT"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s_TIMEOUT: %w", prefix, err)
+		}
+		envOpts = append(envOpts, WithTimeout(d))
+	}
+
+	if v, ok := os.LookupEnv(prefix + "_MAX_RESPONSE_BYTES"); ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s_MAX_RESPONSE_BYTES: %w", prefix, err)
+		}
+		envOpts = append(envOpts, WithMaxResponseBytes(n))
+	}
+
+	return New(append(envOpts, opts...)...), nil
+}
@@ -0,0 +1,36 @@
+package patch
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("PATCHTEST_BASE_URL", "https://example.com")
+	t.Setenv("PATCHTEST_TIMEOUT", "5s")
+	t.Setenv("PATCHTEST_MAX_RESPONSE_BYTES", "1024")
+
+	c, err := NewFromEnv("PATCHTEST")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", c.BaseURL)
+	require.Equal(t, 5*time.Second, c.BaseClient.(*http.Client).Timeout)
+	require.Equal(t, int64(1024), c.MaxResponseBytes)
+
+	// Explicit options take precedence
+	c, err = NewFromEnv("PATCHTEST", WithTimeout(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, time.Second, c.BaseClient.(*http.Client).Timeout)
+
+	// Unset variables leave the defaults
+	c, err = NewFromEnv("PATCHTEST_UNSET")
+	require.NoError(t, err)
+	require.Equal(t, "", c.BaseURL)
+	require.Equal(t, DefaultTimeout, c.BaseClient.(*http.Client).Timeout)
+
+	t.Setenv("PATCHTEST_TIMEOUT", "soon")
+	_, err = NewFromEnv("PATCHTEST")
+	require.EqualError(t, err, `invalid PATCHTEST_TIMEOUT: time: invalid duration "soon"`)
+}
@@ -1,10 +1,26 @@
 package patch
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 )
 
+// ErrCircuitOpen is returned by a CircuitBreaker
+// that is not currently allowing requests.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ErrResponseTooLarge is returned when reading a response body
+// that is larger than the client's MaxResponseBytes.
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// ErrShortBody is returned when reading a response body that
+// ends before the length declared in the Content-Length header,
+// e.g. because the connection was dropped.
+var ErrShortBody = errors.New("response body shorter than Content-Length")
+
 // InvalidMethodError is returned if an unsupported HTTP method is specified
 type InvalidMethodError string
 
@@ -13,8 +29,8 @@ func (method InvalidMethodError) Error() string {
 	return fmt.Sprintf("invalid method %q", string(method))
 }
 
-// BadStatusError is returned if the client's
-// status validator function returns false.
+// BadStatusError is returned if the client's status
+// or response validator function returns false.
 type BadStatusError int
 
 // Error implements the error interface
@@ -31,3 +47,90 @@ type ContentTypeError string
 func (contentType ContentTypeError) Error() string {
 	return fmt.Sprintf("unsupported Content-Type in response %q", string(contentType))
 }
+
+// DecodeError is returned if a response body cannot be decoded. It
+// includes a preview of the body to help debug unexpected responses,
+// e.g. an HTML error page returned with a 200 status.
+type DecodeError struct {
+	// ContentType is the response's Content-Type header
+	ContentType string
+
+	// Decoder is the name of the decoder that was used
+	Decoder string
+
+	// Status is the response's status code
+	Status int
+
+	// Snippet is the start of the body
+	Snippet string
+
+	// Err is the error returned by the decoder
+	Err error
+}
+
+// maxSnippetBytes is the maximum length of a DecodeError's Snippet
+const maxSnippetBytes = 256
+
+func newDecodeError(rsp *Response, dec Decoder, body []byte, err error) *DecodeError {
+	if len(body) > maxSnippetBytes {
+		body = body[:maxSnippetBytes]
+	}
+
+	return &DecodeError{
+		ContentType: rsp.Header.Get("Content-Type"),
+		Decoder:     dec.Name(),
+		Status:      rsp.StatusCode,
+		Snippet:     string(body),
+		Err:         err,
+	}
+}
+
+// Error implements the error interface. The decoder's
+// error already says what failed, so it comes first.
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%v (%d response with Content-Type %q, body %q)",
+		e.Err, e.Status, e.ContentType, e.Snippet)
+}
+
+// Unwrap returns the error returned by the decoder
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned if a request times out, either because
+// the client's timeout elapsed or the context's deadline passed.
+// It matches context.DeadlineExceeded when used with errors.Is.
+type TimeoutError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("request timed out: %v", e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is context.DeadlineExceeded
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// Timeout implements the net.Error interface
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// IsTimeout returns true if err is caused by a request timing out.
+// It returns false if the request's context was canceled.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
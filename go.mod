@@ -1,8 +1,23 @@
 module github.com/jakewright/patch
 
-go 1.14
+go 1.19
 
 require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/aws/aws-sdk-go-v2 v1.24.1
 	github.com/gorilla/schema v1.1.0
+	github.com/klauspost/compress v1.17.2
 	github.com/stretchr/testify v1.6.1
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/net v0.23.0
+	golang.org/x/text v0.14.0
+	google.golang.org/protobuf v1.33.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/aws/smithy-go v1.19.0 // indirect
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 )
@@ -0,0 +1,58 @@
+package patch
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HMACTimestampHeader is the header in which WithHMACSigner
+// sends the Unix time that is included in the signature
+const HMACTimestampHeader = "X-Timestamp"
+
+// WithHMACSigner signs requests using HMAC-SHA256 and sends the
+// hex-encoded signature in the given header. The signed data is the
+// result of canonicalize, which is given the request and its encoded
+// body. If canonicalize is nil, HMACCanonicalRequest is used, and the
+// X-Timestamp header is set to the current Unix time if not already set.
+func WithHMACSigner(secret []byte, header string, canonicalize func(req *http.Request, body []byte) []byte) Option {
+	return WithRequestHook(func(req *http.Request) error {
+		body, err := readBody(req)
+		if err != nil {
+			return err
+		}
+
+		fn := canonicalize
+		if fn == nil {
+			if req.Header.Get(HMACTimestampHeader) == "" {
+				req.Header.Set(HMACTimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+			}
+			fn = HMACCanonicalRequest
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(fn(req, body))
+		req.Header.Set(header, hex.EncodeToString(mac.Sum(nil)))
+
+		return nil
+	})
+}
+
+// HMACCanonicalRequest returns the data signed by WithHMACSigner by
+// default: the X-Timestamp header, the method, the path and query,
+// and the body, separated by newline characters.
+func HMACCanonicalRequest(req *http.Request, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(req.Header.Get(HMACTimestampHeader))
+	b.WriteByte('\n')
+	b.WriteString(req.Method)
+	b.WriteByte('\n')
+	b.WriteString(req.URL.RequestURI())
+	b.WriteByte('\n')
+	b.Write(body)
+	return b.Bytes()
+}
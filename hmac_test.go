@@ -0,0 +1,51 @@
+package patch
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithHMACSigner(t *testing.T) {
+	sign := func(data string) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(data))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	m := NewMockDoer()
+	m.On(http.MethodPost, "/hooks").Respond(http.StatusOK, "")
+	c := NewFromBaseClient(m, WithHMACSigner([]byte("secret"), "X-Signature", nil))
+
+	_, err := c.Post(context.Background(), "/hooks?id=1", map[string]string{"event": "created"}, nil)
+	require.NoError(t, err)
+
+	req := m.Requests()[0]
+	ts, err := strconv.ParseInt(req.Header.Get("X-Timestamp"), 10, 64)
+	require.NoError(t, err)
+	require.InDelta(t, time.Now().Unix(), ts, 5)
+
+	want := sign(req.Header.Get("X-Timestamp") + "\nPOST\n/hooks?id=1\n" + `{"event":"created"}`)
+	require.Equal(t, want, req.Header.Get("X-Signature"))
+
+	// The body is still sent
+	require.Equal(t, `{"event":"created"}`, string(req.Body))
+
+	// Custom canonicalization
+	c = NewFromBaseClient(m, WithHMACSigner([]byte("secret"), "X-Signature", func(req *http.Request, body []byte) []byte {
+		return body
+	}))
+	_, err = c.Post(context.Background(), "/hooks", "hello", nil)
+	require.NoError(t, err)
+
+	req = m.Requests()[1]
+	require.Equal(t, sign(`"hello"`), req.Header.Get("X-Signature"))
+	require.Equal(t, "", req.Header.Get("X-Timestamp"))
+}
@@ -0,0 +1,113 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Op is a JSON Patch (RFC 6902) operation
+type Op struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// MarshalJSON encodes the operation, including the
+// from and value members only if the operation has them
+func (o Op) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"op":   o.Op,
+		"path": o.Path,
+	}
+
+	switch o.Op {
+	case "add", "replace", "test":
+		m["value"] = o.Value
+	case "move", "copy":
+		m["from"] = o.From
+	}
+
+	return json.Marshal(m)
+}
+
+// AddOp returns an operation that adds value at path
+func AddOp(path string, value interface{}) Op {
+	return Op{Op: "add", Path: path, Value: value}
+}
+
+// RemoveOp returns an operation that removes the value at path
+func RemoveOp(path string) Op {
+	return Op{Op: "remove", Path: path}
+}
+
+// ReplaceOp returns an operation that replaces the value at path
+func ReplaceOp(path string, value interface{}) Op {
+	return Op{Op: "replace", Path: path, Value: value}
+}
+
+// MoveOp returns an operation that moves the value at from to path
+func MoveOp(from, path string) Op {
+	return Op{Op: "move", From: from, Path: path}
+}
+
+// CopyOp returns an operation that copies the value at from to path
+func CopyOp(from, path string) Op {
+	return Op{Op: "copy", From: from, Path: path}
+}
+
+// TestOp returns an operation that checks that the value at path
+// equals value. If it doesn't, the server rejects the whole patch.
+func TestOp(path string, value interface{}) Op {
+	return Op{Op: "test", Path: path, Value: value}
+}
+
+// JSONPatchBody is a request body containing a JSON Patch document.
+// It is sent with the application/json-patch+json Content-Type,
+// regardless of the request's encoder.
+type JSONPatchBody struct {
+	Ops []Op
+}
+
+// JSONPatch returns a JSON Patch body containing the operations
+func JSONPatch(ops ...Op) *JSONPatchBody {
+	return &JSONPatchBody{Ops: ops}
+}
+
+func (b *JSONPatchBody) encodeBody() (io.Reader, string, error) {
+	ops := b.Ops
+	if ops == nil {
+		ops = []Op{}
+	}
+
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return bytes.NewReader(data), "application/json-patch+json", nil
+}
+
+// MergePatchBody is a request body containing a JSON Merge Patch
+// (RFC 7386) document. It is sent with the application/merge-patch+json
+// Content-Type, regardless of the request's encoder.
+type MergePatchBody struct {
+	Doc interface{}
+}
+
+// MergePatch returns a JSON Merge Patch body. The doc is usually a
+// map[string]interface{}, where nil values remove members, but can
+// be any value that encodes to a JSON object.
+func MergePatch(doc interface{}) *MergePatchBody {
+	return &MergePatchBody{Doc: doc}
+}
+
+func (b *MergePatchBody) encodeBody() (io.Reader, string, error) {
+	data, err := json.Marshal(b.Doc)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return bytes.NewReader(data), "application/merge-patch+json", nil
+}
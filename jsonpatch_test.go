@@ -0,0 +1,57 @@
+package patch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPatch(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodPatch, "/users/1").Respond(http.StatusOK, "")
+	c := NewFromBaseClient(m, WithEncoder(&EncoderFormURL{}))
+
+	body := JSONPatch(
+		TestOp("/name", "Homer"),
+		ReplaceOp("/name", "Marge"),
+		AddOp("/spouse", nil),
+		RemoveOp("/age"),
+		MoveOp("/a", "/b"),
+		CopyOp("/c", "/d"),
+	)
+
+	_, err := c.Patch(context.Background(), "/users/1", body, nil)
+	require.NoError(t, err)
+
+	req := m.Requests()[0]
+	require.Equal(t, "application/json-patch+json", req.Header.Get("Content-Type"))
+	require.JSONEq(t, `[
+		{"op": "test", "path": "/name", "value": "Homer"},
+		{"op": "replace", "path": "/name", "value": "Marge"},
+		{"op": "add", "path": "/spouse", "value": null},
+		{"op": "remove", "path": "/age"},
+		{"op": "move", "from": "/a", "path": "/b"},
+		{"op": "copy", "from": "/c", "path": "/d"}
+	]`, string(req.Body))
+
+	// An empty patch is an empty array
+	_, err = c.Patch(context.Background(), "/users/1", JSONPatch(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "[]", string(m.Requests()[1].Body))
+}
+
+func TestMergePatch(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodPatch, "/users/1").Respond(http.StatusOK, "")
+	c := NewFromBaseClient(m)
+
+	body := MergePatch(map[string]interface{}{"name": "Marge", "age": nil})
+	_, err := c.Patch(context.Background(), "/users/1", body, nil)
+	require.NoError(t, err)
+
+	req := m.Requests()[0]
+	require.Equal(t, "application/merge-patch+json", req.Header.Get("Content-Type"))
+	require.JSONEq(t, `{"name": "Marge", "age": null}`, string(req.Body))
+}
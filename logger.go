@@ -0,0 +1,222 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Logger writes structured logs. The keyvals are alternating keys
+// and values, e.g. "method", "GET", "status", 200.
+type Logger interface {
+	Log(ctx context.Context, keyvals ...interface{})
+}
+
+// NewStdLogger returns a Logger that writes to l in
+// the logfmt style, e.g. method=GET status=200.
+func NewStdLogger(l *log.Logger) Logger {
+	return &stdLogger{l: l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+// Log implements the Logger interface
+func (s *stdLogger) Log(_ context.Context, keyvals ...interface{}) {
+	var b strings.Builder
+	for i := 0; i < len(keyvals); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		var v interface{}
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+
+		b.WriteString(fmt.Sprint(keyvals[i]))
+		b.WriteByte('=')
+		b.WriteString(formatLogValue(v))
+	}
+
+	s.l.Print(b.String())
+}
+
+// formatLogValue formats v, quoting it if it
+// contains spaces, quotes or other special characters.
+func formatLogValue(v interface{}) string {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case []byte:
+		s = string(t)
+	case error:
+		s = t.Error()
+	default:
+		s = fmt.Sprint(t)
+	}
+
+	needsQuotes := s == "" || strings.IndexFunc(s, func(r rune) bool {
+		return r == '"' || r == '=' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) >= 0
+
+	if needsQuotes {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// RequestLogger is a Doer that logs the method, URL, status and
+// duration of every request. Request and response bodies are also
+// logged for a sample of requests, which keeps the cost of logging
+// bodies on high-traffic services under control.
+type RequestLogger struct {
+	// Next is the Doer that requests are passed to
+	Next Doer
+
+	// Logger is where the logs are written.
+	// If nil, the standard logger is used.
+	Logger Logger
+
+	// BodySampleRate is the fraction of requests, between 0 and 1,
+	// for which the request and response bodies are logged.
+	// Sampled response bodies are read into memory but can still
+	// be read and decoded as normal.
+	BodySampleRate float64
+
+	// RequestIDHeader, if set, is the request header
+	// whose value is logged as the request_id.
+	RequestIDHeader string
+
+	// Redactor is called with copies of the headers and body of
+	// sampled requests and responses before they are logged. It
+	// returns the body to log and can modify the headers, e.g. to
+	// mask passwords and tokens. The request and response that are
+	// sent and returned are never modified. If nil, DefaultRedactor
+	// is used. Custom redactors should usually call it too.
+	Redactor func(header http.Header, body []byte) []byte
+}
+
+// sensitiveHeaders are the headers masked by DefaultRedactor
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// DefaultRedactor replaces the values of the Authorization,
+// Proxy-Authorization, Cookie and Set-Cookie headers with
+// "REDACTED". The body is returned unchanged.
+func DefaultRedactor(header http.Header, body []byte) []byte {
+	for _, key := range sensitiveHeaders {
+		for i := range header[key] {
+			header[key][i] = "REDACTED"
+		}
+	}
+
+	return body
+}
+
+// NewRequestLogger returns a RequestLogger that wraps next
+func NewRequestLogger(next Doer, logger Logger) *RequestLogger {
+	return &RequestLogger{
+		Next:   next,
+		Logger: logger,
+	}
+}
+
+// Do logs the request and passes it to the next Doer
+func (l *RequestLogger) Do(req *http.Request) (*http.Response, error) {
+	sample := l.BodySampleRate > 0 && rand.Float64() < l.BodySampleRate
+
+	var reqBody []byte
+	if sample && req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		reqBody = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	start := time.Now()
+	rsp, err := l.Next.Do(req)
+	duration := time.Since(start)
+
+	keyvals := []interface{}{"method", req.Method, "url", req.URL.String()}
+
+	if l.RequestIDHeader != "" {
+		keyvals = append(keyvals, "request_id", req.Header.Get(l.RequestIDHeader))
+	}
+
+	if name := OperationNameFromContext(req.Context()); name != "" {
+		keyvals = append(keyvals, "operation", name)
+	}
+
+	if err != nil {
+		if rsp != nil {
+			_ = rsp.Body.Close()
+		}
+
+		l.log(req.Context(), append(keyvals, "error", err, "duration", duration)...)
+		return nil, err
+	}
+
+	keyvals = append(keyvals, "status", rsp.StatusCode, "duration", duration)
+
+	if !sample {
+		l.log(req.Context(), keyvals...)
+		return rsp, nil
+	}
+
+	// Replace the body with a bufCloser so that
+	// it can still be read by Response.BodyBytes.
+	rspBody, err := ioutil.ReadAll(rsp.Body)
+	_ = rsp.Body.Close()
+	if err != nil {
+		l.log(req.Context(), append(keyvals, "error", fmt.Errorf("failed to read response body: %w", err))...)
+		return nil, err
+	}
+	rsp.Body = newBufCloser(rspBody)
+
+	reqHeader, reqBody := l.redact(req.Header, reqBody)
+	rspHeader, rspBody := l.redact(rsp.Header, rspBody)
+
+	keyvals = append(keyvals,
+		"request_headers", reqHeader, "request_body", string(reqBody),
+		"response_headers", rspHeader, "response_body", string(rspBody),
+	)
+	l.log(req.Context(), keyvals...)
+
+	return rsp, nil
+}
+
+// redact returns copies of the header and
+// body with sensitive values removed
+func (l *RequestLogger) redact(header http.Header, body []byte) (http.Header, []byte) {
+	redactor := l.Redactor
+	if redactor == nil {
+		redactor = DefaultRedactor
+	}
+
+	h := header.Clone()
+	return h, redactor(h, append([]byte(nil), body...))
+}
+
+func (l *RequestLogger) log(ctx context.Context, keyvals ...interface{}) {
+	logger := l.Logger
+	if logger == nil {
+		logger = NewStdLogger(log.Default())
+	}
+
+	logger.Log(ctx, keyvals...)
+}
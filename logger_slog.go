@@ -0,0 +1,31 @@
+//go:build go1.21
+
+package patch
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a Logger that writes to l. Entries
+// that have an error are logged at the error level.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// Log implements the Logger interface
+func (s *slogLogger) Log(ctx context.Context, keyvals ...interface{}) {
+	level := slog.LevelInfo
+	for i := 0; i < len(keyvals); i += 2 {
+		if keyvals[i] == "error" {
+			level = slog.LevelError
+			break
+		}
+	}
+
+	s.l.Log(ctx, level, "http request", keyvals...)
+}
@@ -0,0 +1,33 @@
+//go:build go1.21
+
+package patch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := NewSlogLogger(slog.New(h))
+
+	l.Log(context.Background(), "method", "GET", "status", 200)
+	require.Equal(t, "level=INFO msg=\"http request\" method=GET status=200\n", buf.String())
+
+	buf.Reset()
+	l.Log(context.Background(), "method", "GET", "error", errors.New("failed"))
+	require.Equal(t, "level=ERROR msg=\"http request\" method=GET error=failed\n", buf.String())
+}
@@ -0,0 +1,180 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogger(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodPost, "/users").
+		Respond(http.StatusCreated, `{"result": "created"}`).
+		Header("Content-Type", "application/json")
+
+	var buf bytes.Buffer
+	l := NewRequestLogger(m, NewStdLogger(log.New(&buf, "", 0)))
+	c := NewFromBaseClient(l, WithBaseURL("http://example.com"))
+
+	// Bodies are not logged if the request isn't sampled
+	_, err := c.Post(context.Background(), "/users", map[string]string{"name": "Homer"}, nil)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(buf.String(), "method=POST url=http://example.com/users status=201 duration="))
+	require.NotContains(t, buf.String(), "body")
+
+	// Bodies are logged if the request is sampled
+	buf.Reset()
+	l.BodySampleRate = 1
+
+	var v testResult
+	_, err = c.Post(context.Background(), "/users", map[string]string{"name": "Homer"}, &v)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), `request_body="{\"name\":\"Homer\"}"`)
+	require.Contains(t, buf.String(), `response_body="{\"result\": \"created\"}"`)
+
+	// Sampling doesn't break decoding or the request body
+	require.Equal(t, "created", v.Result)
+	require.Equal(t, `{"name":"Homer"}`, string(m.Requests()[1].Body))
+
+	// Errors are logged
+	buf.Reset()
+	_, err = c.Get(context.Background(), "/missing", nil)
+	require.Error(t, err)
+	require.Contains(t, buf.String(), "method=GET url=http://example.com/missing error=")
+
+	// The request ID and operation name are logged
+	buf.Reset()
+	l.BodySampleRate = 0
+	l.RequestIDHeader = "X-Request-ID"
+	c = c.Clone(WithAutoRequestID("X-Request-ID"))
+	rsp, err := c.Post(WithOperationName(context.Background(), "CreateUser"), "/users", nil, nil)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "request_id="+rsp.RequestID()+" operation=CreateUser")
+}
+
+type logRecorder struct {
+	keyvals []interface{}
+}
+
+func (r *logRecorder) Log(_ context.Context, keyvals ...interface{}) {
+	r.keyvals = keyvals
+}
+
+func TestRequestLogger_structured(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users").Respond(http.StatusOK, "")
+	m.On(http.MethodGet, "/broken").Error(errors.New("connection refused"))
+
+	rec := &logRecorder{}
+	c := NewFromBaseClient(NewRequestLogger(m, rec))
+
+	_, err := c.Get(context.Background(), "/users", nil)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"method", "GET", "url", "/users", "status", 200}, rec.keyvals[:6])
+	require.Equal(t, "duration", rec.keyvals[6])
+
+	_, err = c.Get(context.Background(), "/broken", nil)
+	require.Error(t, err)
+	require.Equal(t, "error", rec.keyvals[4])
+}
+
+func TestRequestLogger_redact(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodPost, "/login").
+		Respond(http.StatusOK, `{"token": "secret"}`).
+		Header("Set-Cookie", "session=secret")
+
+	rec := &logRecorder{}
+	l := NewRequestLogger(m, rec)
+	l.BodySampleRate = 1
+	c := NewFromBaseClient(l, WithBearerToken("abc"))
+
+	logged := func(key string) interface{} {
+		for i := 0; i+1 < len(rec.keyvals); i += 2 {
+			if rec.keyvals[i] == key {
+				return rec.keyvals[i+1]
+			}
+		}
+		return nil
+	}
+
+	// Sensitive headers are masked by default
+	rsp, err := c.Post(context.Background(), "/login", map[string]string{"password": "hunter2"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"REDACTED"}, logged("request_headers").(http.Header).Values("Authorization"))
+	require.Equal(t, []string{"REDACTED"}, logged("response_headers").(http.Header).Values("Set-Cookie"))
+	require.Equal(t, `{"password":"hunter2"}`, logged("request_body"))
+
+	// The real request and response are untouched
+	require.Equal(t, "Bearer abc", m.Requests()[0].Header.Get("Authorization"))
+	require.Equal(t, "session=secret", rsp.Header.Get("Set-Cookie"))
+
+	// A custom redactor can scrub the body
+	l.Redactor = func(header http.Header, body []byte) []byte {
+		header.Set("Content-Type", "scrubbed")
+		return bytes.ReplaceAll(DefaultRedactor(header, body), []byte("secret"), []byte("***"))
+	}
+
+	rsp, err = c.Post(context.Background(), "/login", []byte("password=secret"), nil)
+	require.NoError(t, err)
+	require.Equal(t, "password=***", logged("request_body"))
+	require.Equal(t, `{"token": "***"}`, logged("response_body"))
+	require.Equal(t, "REDACTED", logged("request_headers").(http.Header).Get("Authorization"))
+	require.Equal(t, "scrubbed", logged("response_headers").(http.Header).Get("Content-Type"))
+
+	// The response body can still be read
+	b, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, `{"token": "secret"}`, b)
+	require.Equal(t, "password=secret", string(m.Requests()[1].Body))
+}
+
+func TestRequestLogger_closesBodyOnError(t *testing.T) {
+	errRead := errors.New("connection reset")
+	body := &closeRecorder{Reader: iotest.ErrReader(errRead)}
+	rec := &logRecorder{}
+	l := NewRequestLogger(doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body}, nil
+	}), rec)
+	l.BodySampleRate = 1
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	// A failure to read the sampled response body is logged and returned
+	rsp, err := l.Do(req)
+	require.Equal(t, errRead, err)
+	require.Nil(t, rsp)
+	require.True(t, body.closed)
+	require.Equal(t, "error", rec.keyvals[len(rec.keyvals)-2])
+
+	// A response returned alongside an error is closed
+	body = &closeRecorder{Reader: strings.NewReader("")}
+	l.Next = doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: body}, errRead
+	})
+	rsp, err = l.Do(req)
+	require.Equal(t, errRead, err)
+	require.Nil(t, rsp)
+	require.True(t, body.closed)
+}
+
+func TestNewStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	NewStdLogger(log.New(&buf, "", 0)).Log(context.Background(),
+		"plain", "value",
+		"spaces", "a b",
+		"empty", "",
+		"number", 42,
+		"error", errors.New("failed"),
+		"odd",
+	)
+	require.Equal(t, `plain=value spaces="a b" empty="" number=42 error=failed odd=<nil>`+"\n", buf.String())
+}
@@ -0,0 +1,160 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// MockDoer is a Doer that returns canned responses without making
+// network calls, for use in tests. Responses are matched by method and
+// URL, and every request received is recorded for later assertions.
+//
+//	m := patch.NewMockDoer()
+//	m.On("GET", "/users/1").Respond(200, `{"name": "Homer"}`).
+//	    Header("Content-Type", "application/json")
+//	c := patch.NewFromBaseClient(m)
+type MockDoer struct {
+	mu       sync.Mutex
+	routes   []*MockRoute
+	requests []*RecordedRequest
+}
+
+// MockRoute is a canned response returned by a MockDoer. Its
+// setters are safe to call while the MockDoer is handling requests.
+type MockRoute struct {
+	// mu is the MockDoer's mutex
+	mu *sync.Mutex
+
+	method string
+	url    string
+	status int
+	header http.Header
+	body   []byte
+	err    error
+}
+
+// RecordedRequest is a request received by a MockDoer
+type RecordedRequest struct {
+	*http.Request
+
+	// Body is the request body, which has been read
+	Body []byte
+}
+
+// NewMockDoer returns a MockDoer with no routes
+func NewMockDoer() *MockDoer {
+	return &MockDoer{}
+}
+
+// On adds a route that matches requests with the given method and URL.
+// The URL matches if it is equal to the full request URL or to its path.
+// An empty method matches any method. Routes are matched in the order they
+// are added. By default, the route responds with an empty 200 OK.
+func (m *MockDoer) On(method, url string) *MockRoute {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := &MockRoute{
+		mu:     &m.mu,
+		method: method,
+		url:    url,
+		status: http.StatusOK,
+		header: http.Header{},
+	}
+	m.routes = append(m.routes, r)
+	return r
+}
+
+// Respond sets the status code and body of the response
+func (r *MockRoute) Respond(status int, body string) *MockRoute {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status = status
+	r.body = []byte(body)
+	return r
+}
+
+// Header sets a header on the response
+func (r *MockRoute) Header(key, value string) *MockRoute {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.header.Set(key, value)
+	return r
+}
+
+// Error makes the route return an error instead of a response
+func (r *MockRoute) Error(err error) *MockRoute {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.err = err
+	return r
+}
+
+// Requests returns the requests received so far, in order
+func (m *MockDoer) Requests() []*RecordedRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*RecordedRequest(nil), m.requests...)
+}
+
+// Do records the request and returns the response of the first
+// matching route. An error is returned if no route matches.
+func (m *MockDoer) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, &RecordedRequest{Request: req, Body: body})
+	route := m.match(req)
+
+	if route == nil {
+		return nil, fmt.Errorf("no mock route for %s %s", req.Method, req.URL)
+	}
+
+	if route.err != nil {
+		return nil, route.err
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(route.status) + " " + http.StatusText(route.status),
+		StatusCode:    route.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        route.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(route.body)),
+		ContentLength: int64(len(route.body)),
+		Request:       req,
+	}, nil
+}
+
+func (m *MockDoer) match(req *http.Request) *MockRoute {
+	for _, r := range m.routes {
+		if r.method != "" && r.method != req.Method {
+			continue
+		}
+
+		if r.url == req.URL.String() || r.url == req.URL.Path {
+			return r
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockDoer(t *testing.T) {
+	m := NewMockDoer()
+	m.On(http.MethodGet, "/users/1").
+		Respond(http.StatusOK, `{"result": "Homer"}`).
+		Header("Content-Type", "application/json")
+	m.On(http.MethodPost, "http://example.com/users").
+		Respond(http.StatusCreated, "")
+	m.On("", "/down").Error(errors.New("connection refused"))
+
+	c := NewFromBaseClient(m, WithBaseURL("http://example.com"))
+
+	var v testResult
+	_, err := c.Get(context.Background(), "/users/1", &v)
+	require.NoError(t, err)
+	require.Equal(t, "Homer", v.Result)
+
+	rsp, err := c.Post(context.Background(), "/users", map[string]string{"name": "Marge"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, rsp.StatusCode)
+
+	_, err = c.Delete(context.Background(), "/down", nil, nil)
+	require.EqualError(t, err, "connection refused")
+
+	_, err = c.Get(context.Background(), "/missing", nil)
+	require.Error(t, err)
+
+	requests := m.Requests()
+	require.Len(t, requests, 4)
+	require.Equal(t, http.MethodPost, requests[1].Method)
+	require.Equal(t, `{"name":"Marge"}`, string(requests[1].Body))
+}
+
+func TestMockDoer_configureConcurrently(t *testing.T) {
+	m := NewMockDoer()
+	route := m.On(http.MethodGet, "/users")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			route.Respond(http.StatusOK, "ok").Header("X-Test", "1")
+		}()
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+			require.NoError(t, err)
+			_, err = m.Do(req)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+}
@@ -1,9 +1,15 @@
 package patch
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"time"
+
+	"golang.org/x/net/http2"
 )
 
 // DefaultTimeout is the default time limit for requests made by the client.
@@ -22,6 +28,75 @@ func WithBaseURL(url string) Option {
 	}
 }
 
+// WithPathJoin appends request URLs to the path of the BaseURL
+// instead of resolving them, so that the base path is preserved.
+// With a BaseURL of https://example.com/api/v1, the request URL
+// /users is sent to https://example.com/api/v1/users.
+func WithPathJoin() Option {
+	return func(c *Client) {
+		c.PathJoin = true
+	}
+}
+
+// WithAllowedMethods allows requests to use the given extension
+// methods, e.g. PROPFIND or MKCOL, as well as the standard methods.
+func WithAllowedMethods(methods ...string) Option {
+	return func(c *Client) {
+		c.AllowedMethods = append(c.AllowedMethods, methods...)
+	}
+}
+
+// WithMaxConcurrency limits the number of requests that the client
+// sends concurrently. A request holds its slot until its response
+// body is closed, so callers that read the body themselves must
+// close it. Other requests wait for a free slot or until the
+// request's context is done. Clones made after this option is
+// applied share the limit.
+func WithMaxConcurrency(n int) Option {
+	return func(c *Client) {
+		if n <= 0 {
+			c.sem = nil
+			return
+		}
+
+		c.sem = make(chan struct{}, n)
+	}
+}
+
+// WithBaseContext sets the context used for requests that don't have
+// one. Canceling ctx, e.g. on shutdown, aborts all such requests.
+func WithBaseContext(ctx context.Context) Option {
+	return func(c *Client) {
+		c.BaseContext = ctx
+	}
+}
+
+// WithDefaultQuery sets a query parameter that is added to every
+// request, unless the request's URL or Query already sets the key.
+func WithDefaultQuery(key, value string) Option {
+	return func(c *Client) {
+		if c.DefaultQuery == nil {
+			c.DefaultQuery = url.Values{}
+		}
+
+		c.DefaultQuery.Set(key, value)
+	}
+}
+
+// WithDefaultQueryValues is like WithDefaultQuery
+// but sets all of the keys in values.
+func WithDefaultQueryValues(values url.Values) Option {
+	return func(c *Client) {
+		if c.DefaultQuery == nil {
+			c.DefaultQuery = url.Values{}
+		}
+
+		for key, vs := range values {
+			c.DefaultQuery[key] = append([]string(nil), vs...)
+		}
+	}
+}
+
 func WithTimeout(d time.Duration) Option {
 	return func(c *Client) {
 		switch bc := c.BaseClient.(type) {
@@ -34,14 +109,386 @@ func WithTimeout(d time.Duration) Option {
 	}
 }
 
+// WithBaseClient replaces the base client used to send requests. Options
+// that modify the base client, such as WithTimeout, apply to whichever
+// base client is set when they run, so they should come after this one.
+func WithBaseClient(d Doer) Option {
+	return func(c *Client) {
+		c.BaseClient = d
+	}
+}
+
 func WithStatusValidator(f func(int) bool) Option {
 	return func(c *Client) {
 		c.StatusValidator = f
 	}
 }
 
+// WithResponseValidator sets a function that validates the whole
+// response. It takes precedence over the status validator and must
+// not read the response body.
+func WithResponseValidator(f func(*http.Response) bool) Option {
+	return func(c *Client) {
+		c.ResponseValidator = f
+	}
+}
+
+// WithErrorDecoder sets a function that converts the body of a
+// response that fails validation into an error, e.g. an API's
+// structured error payload. See Client.ErrorDecoder.
+func WithErrorDecoder(fn func(status int, body []byte) error) Option {
+	return func(c *Client) {
+		c.ErrorDecoder = fn
+	}
+}
+
 func WithEncoder(enc Encoder) Option {
 	return func(c *Client) {
 		c.DefaultEncoder = enc
 	}
 }
+
+// WithDecoder registers a Decoder for the given Content-Type.
+// See Client.RegisterDecoder for details.
+func WithDecoder(contentType string, dec Decoder) Option {
+	return func(c *Client) {
+		c.RegisterDecoder(contentType, dec)
+	}
+}
+
+// WithDecompressor registers a Decompressor for the given
+// Content-Encoding. See Client.RegisterDecompressor for details.
+func WithDecompressor(encoding string, dec Decompressor) Option {
+	return func(c *Client) {
+		c.RegisterDecompressor(encoding, dec)
+	}
+}
+
+// WithDefaultResponseContentType sets the content type that is
+// assumed when decoding responses without a Content-Type header,
+// e.g. "application/json" for minimal servers that omit it.
+func WithDefaultResponseContentType(contentType string) Option {
+	return func(c *Client) {
+		c.DefaultResponseContentType = contentType
+	}
+}
+
+// WithMaxResponseBytes limits the size of response bodies. Reading
+// a larger body returns ErrResponseTooLarge.
+func WithMaxResponseBytes(n int64) Option {
+	return func(c *Client) {
+		c.MaxResponseBytes = n
+	}
+}
+
+// WithAutoAccept sets an Accept header listing JSON, the default
+// response content type and the content types of the registered
+// decoders on requests that do not already have one.
+func WithAutoAccept() Option {
+	return func(c *Client) {
+		c.AutoAccept = true
+	}
+}
+
+// WithBodyTransformer sets a function that transforms response
+// bodies before they are decoded. For example, it can strip the
+// )]}' prefix that some APIs add to JSON to prevent XSSI attacks.
+func WithBodyTransformer(fn func([]byte) ([]byte, error)) Option {
+	return func(c *Client) {
+		c.BodyTransformer = fn
+	}
+}
+
+// WithTransparentGzip sets whether the client explicitly requests
+// compressed responses and decompresses them, regardless of the Doer.
+// Despite the name, all of the client's decompressors are used.
+func WithTransparentGzip(enabled bool) Option {
+	return func(c *Client) {
+		c.TransparentGzip = enabled
+	}
+}
+
+// WithHeaderFunc sets a function that computes the value of the header
+// with the given key for each request. If the function returns an error,
+// the request is aborted. A header set on the request takes precedence.
+func WithHeaderFunc(key string, fn func(*Request) (string, error)) Option {
+	return func(c *Client) {
+		if c.HeaderFuncs == nil {
+			c.HeaderFuncs = make(map[string]func(*Request) (string, error))
+		}
+
+		c.HeaderFuncs[key] = fn
+	}
+}
+
+// WithAutoRequestID sends a random ID in the given header, e.g.
+// X-Request-ID, with each request that doesn't already have one.
+// The ID is available from Response.RequestID.
+func WithAutoRequestID(header string) Option {
+	return func(c *Client) {
+		c.RequestIDHeader = header
+	}
+}
+
+// WithRequestHook adds a function that is called with each request
+// before it is sent. If the function returns an error, the request is
+// aborted. Hooks are called in the order they are added.
+func WithRequestHook(fn func(*http.Request) error) Option {
+	return func(c *Client) {
+		c.RequestHooks = append(c.RequestHooks, fn)
+	}
+}
+
+// WithRequestEditor adds a function that can modify the fully built
+// request, with its URL resolved, body encoded and headers set, before
+// it is sent. It is a request hook that is also given the request's
+// context, so editors and hooks are called in the order they are added.
+func WithRequestEditor(fn func(ctx context.Context, req *http.Request) error) Option {
+	return WithRequestHook(func(req *http.Request) error {
+		return fn(req.Context(), req)
+	})
+}
+
+// WithResponseHook adds a function that is called with each response
+// before it is validated. If the function returns an error, it is
+// returned to the caller. Hooks are called in the order they are added.
+func WithResponseHook(fn func(*http.Response) error) Option {
+	return func(c *Client) {
+		c.ResponseHooks = append(c.ResponseHooks, fn)
+	}
+}
+
+// WithBearerToken sets a static token that is sent
+// in the Authorization header of every request.
+func WithBearerToken(token string) Option {
+	return WithTokenSource(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// WithTokenSource sets a function that is called before each
+// request to obtain a (possibly refreshed) bearer token. The
+// function is passed the request's context.
+func WithTokenSource(fn func(context.Context) (string, error)) Option {
+	return func(c *Client) {
+		c.TokenSource = fn
+	}
+}
+
+// WithProxy routes requests through the proxy at the given URL.
+// The http, https and socks5 schemes are supported.
+func WithProxy(proxyURL string) Option {
+	return func(c *Client) {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			panic(fmt.Errorf("invalid proxy URL: %w", err))
+		}
+
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			panic(fmt.Errorf("unsupported proxy scheme %q", u.Scheme))
+		}
+
+		httpTransport(c, "proxy").Proxy = http.ProxyURL(u)
+	}
+}
+
+// WithCheckRedirect sets the redirect policy of the base *http.Client.
+// See http.Client.CheckRedirect for details.
+func WithCheckRedirect(fn func(req *http.Request, via []*http.Request) error) Option {
+	return func(c *Client) {
+		httpClient(c, "redirect policy").CheckRedirect = fn
+	}
+}
+
+// WithNoRedirects stops the base *http.Client from following
+// redirects. The 3xx response is returned instead.
+func WithNoRedirects() Option {
+	return WithCheckRedirect(func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	})
+}
+
+// WithMethodTimeout sets a time limit for requests with the given
+// method, e.g. to make GET requests time out sooner than POST requests.
+// It only applies to requests whose context doesn't have a deadline,
+// and covers reading the body. The client's timeout still applies.
+func WithMethodTimeout(method string, d time.Duration) Option {
+	return func(c *Client) {
+		if c.MethodTimeouts == nil {
+			c.MethodTimeouts = make(map[string]time.Duration)
+		}
+
+		c.MethodTimeouts[method] = d
+	}
+}
+
+// WithDialTimeout limits the time taken to establish a TCP connection
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		httpTransport(c, "dial timeout").DialContext = (&net.Dialer{
+			Timeout:   d,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+}
+
+// WithTLSHandshakeTimeout limits the time taken to perform the TLS handshake
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		httpTransport(c, "TLS handshake timeout").TLSHandshakeTimeout = d
+	}
+}
+
+// WithResponseHeaderTimeout limits the time spent waiting for the
+// response headers after the request has been written. It does not
+// include the time taken to read the response body.
+func WithResponseHeaderTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		httpTransport(c, "response header timeout").ResponseHeaderTimeout = d
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the server's TLS
+// certificate chain and host name. This is for testing against servers
+// with self-signed certificates. WARNING: it makes connections vulnerable
+// to man-in-the-middle attacks, so never use it in production.
+func WithInsecureSkipVerify() Option {
+	return func(c *Client) {
+		tlsConfig(c, "InsecureSkipVerify").InsecureSkipVerify = true
+	}
+}
+
+// WithClientCert presents cert to servers that request a client
+// certificate, for mutual TLS. It panics if the base client is not
+// an *http.Client.
+func WithClientCert(cert tls.Certificate) Option {
+	return func(c *Client) {
+		cfg := tlsConfig(c, "client certificate")
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+}
+
+// WithClientCertFile is like WithClientCert but loads a PEM-encoded
+// certificate and private key from the given files. It panics if
+// they cannot be loaded.
+func WithClientCertFile(certFile, keyFile string) Option {
+	return func(c *Client) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			panic(fmt.Errorf("failed to load client certificate: %w", err))
+		}
+
+		WithClientCert(cert)(c)
+	}
+}
+
+// HTTP2Settings configure the HTTP/2 connections of the base
+// *http.Client. Zero values use the defaults of golang.org/x/net/http2.
+type HTTP2Settings struct {
+	// ReadIdleTimeout is the time after which a health check
+	// ping is sent if no frame has been received. Zero
+	// disables health checks.
+	ReadIdleTimeout time.Duration
+
+	// PingTimeout is the time after which the connection is
+	// closed if a health check ping isn't answered.
+	PingTimeout time.Duration
+
+	// WriteByteTimeout is the time after which the connection
+	// is closed if no data can be written to it.
+	WriteByteTimeout time.Duration
+
+	// StrictMaxConcurrentStreams makes requests wait for a free
+	// stream rather than opening a new connection when the
+	// server's limit on concurrent streams has been reached.
+	StrictMaxConcurrentStreams bool
+
+	// MaxHeaderListSize limits the size of response headers
+	MaxHeaderListSize uint32
+
+	// MaxReadFrameSize is the largest frame the client will accept
+	MaxReadFrameSize uint32
+}
+
+// WithHTTP2 configures the transport of the base *http.Client to use
+// HTTP/2 over TLS with the given settings, even if the transport has
+// been customised (e.g. by WithProxy). It panics if the transport has
+// already been configured for HTTP/2, e.g. by an earlier WithHTTP2.
+func WithHTTP2(settings HTTP2Settings) Option {
+	return func(c *Client) {
+		tr := httpTransport(c, "HTTP/2")
+		tr.ForceAttemptHTTP2 = true
+
+		t2, err := http2.ConfigureTransports(tr)
+		if err != nil {
+			panic(fmt.Errorf("failed to configure HTTP/2: %w", err))
+		}
+
+		t2.ReadIdleTimeout = settings.ReadIdleTimeout
+		t2.PingTimeout = settings.PingTimeout
+		t2.WriteByteTimeout = settings.WriteByteTimeout
+		t2.StrictMaxConcurrentStreams = settings.StrictMaxConcurrentStreams
+		t2.MaxHeaderListSize = settings.MaxHeaderListSize
+		t2.MaxReadFrameSize = settings.MaxReadFrameSize
+	}
+}
+
+// WithH2C replaces the transport of the base *http.Client with one
+// that speaks HTTP/2 over plain TCP without upgrading (h2c with prior
+// knowledge). This is useful for internal services that multiplex many
+// requests over a single connection. Because the transport is replaced,
+// it should come before options that modify the transport, which will
+// otherwise panic.
+func WithH2C() Option {
+	return func(c *Client) {
+		httpClient(c, "h2c transport").Transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		}
+	}
+}
+
+// httpClient returns the client's base *http.Client.
+// It panics if the base client is of another type.
+func httpClient(c *Client, setting string) *http.Client {
+	bc, ok := c.BaseClient.(*http.Client)
+	if !ok {
+		panic(fmt.Errorf("cannot set %s on base client of type %T", setting, c.BaseClient))
+	}
+
+	return bc
+}
+
+// httpTransport returns the transport of the client's base *http.Client,
+// creating one from http.DefaultTransport if it is nil. It panics if the
+// base client or its transport is of another type.
+func httpTransport(c *Client, setting string) *http.Transport {
+	bc := httpClient(c, setting)
+
+	switch t := bc.Transport.(type) {
+	case nil:
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		bc.Transport = tr
+		return tr
+	case *http.Transport:
+		return t
+	}
+
+	panic(fmt.Errorf("cannot set %s on transport of type %T", setting, bc.Transport))
+}
+
+// tlsConfig returns the TLS config of the client's
+// transport, creating one if necessary.
+func tlsConfig(c *Client, setting string) *tls.Config {
+	tr := httpTransport(c, setting)
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = &tls.Config{}
+	}
+
+	return tr.TLSClientConfig
+}
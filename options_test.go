@@ -0,0 +1,180 @@
+package patch
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProxy(t *testing.T) {
+	c := New(WithProxy("socks5://localhost:1080"))
+	tr := c.BaseClient.(*http.Client).Transport.(*http.Transport)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	u, err := tr.Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, "socks5://localhost:1080", u.String())
+
+	require.Panics(t, func() { New(WithProxy("ftp://localhost")) })
+	require.Panics(t, func() {
+		NewFromBaseClient(doerFunc(nil), WithProxy("http://localhost:8080"))
+	})
+}
+
+func TestWithCheckRedirect(t *testing.T) {
+	require.Panics(t, func() { NewFromBaseClient(doerFunc(nil), WithNoRedirects()) })
+}
+
+func TestWithHTTP2(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.Proto))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewUnstartedServer(h)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Use the server's certificate but not its HTTP/2 configuration
+	tr := &http.Transport{TLSClientConfig: srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()}
+	c := NewFromBaseClient(&http.Client{Transport: tr}, WithHTTP2(HTTP2Settings{
+		ReadIdleTimeout: 30 * time.Second,
+		PingTimeout:     5 * time.Second,
+	}))
+	require.Contains(t, tr.TLSNextProto, "h2")
+
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/2.0", rspBody)
+
+	// The transport can only be configured once
+	require.Panics(t, func() { New(WithHTTP2(HTTP2Settings{}), WithHTTP2(HTTP2Settings{})) })
+
+	// Transport options cannot be applied after WithH2C
+	require.Panics(t, func() { New(WithH2C(), WithProxy("http://localhost:8080")) })
+}
+
+func TestTransportTimeouts(t *testing.T) {
+	c := New(
+		WithDialTimeout(time.Second),
+		WithTLSHandshakeTimeout(2*time.Second),
+		WithResponseHeaderTimeout(3*time.Second),
+	)
+
+	bc := c.BaseClient.(*http.Client)
+	tr := bc.Transport.(*http.Transport)
+	require.NotNil(t, tr.DialContext)
+	require.Equal(t, 2*time.Second, tr.TLSHandshakeTimeout)
+	require.Equal(t, 3*time.Second, tr.ResponseHeaderTimeout)
+
+	// The overall timeout is unchanged
+	require.Equal(t, DefaultTimeout, bc.Timeout)
+}
+
+func TestWithBaseClient(t *testing.T) {
+	bc := &http.Client{}
+	c := New(WithBaseClient(bc), WithTimeout(time.Second))
+	require.Same(t, bc, c.BaseClient)
+	require.Equal(t, time.Second, bc.Timeout)
+
+	m := NewMockDoer()
+	c = New(WithBaseClient(m))
+	require.Same(t, m, c.BaseClient)
+}
+
+func TestWithInsecureSkipVerify(t *testing.T) {
+	require.Panics(t, func() { NewFromBaseClient(doerFunc(nil), WithInsecureSkipVerify()) })
+}
+
+func TestWithClientCert(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewUnstartedServer(h)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Generate a self-signed client certificate
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	// The request fails without a certificate
+	c := NewFromBaseClient(srv.Client())
+	_, err = c.Get(context.Background(), srv.URL, nil)
+	require.Error(t, err)
+
+	c = NewFromBaseClient(srv.Client(), WithClientCertFile(certFile, keyFile))
+	rsp, err := c.Get(context.Background(), srv.URL, nil)
+	require.NoError(t, err)
+	rspBody, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "client", rspBody)
+
+	require.Panics(t, func() { New(WithClientCertFile(filepath.Join(dir, "missing.crt"), keyFile)) })
+	require.Panics(t, func() { NewFromBaseClient(doerFunc(nil), WithClientCert(tls.Certificate{})) })
+}
+
+func TestWithDefaultQuery(t *testing.T) {
+	c := New(
+		WithBaseURL("http://example.com"),
+		WithDefaultQuery("api_key", "secret"),
+		WithDefaultQueryValues(url.Values{"format": {"json"}, "page": {"1"}}),
+	)
+
+	req, err := c.BuildRequest(&Request{Method: http.MethodGet, URL: "/users"})
+	require.NoError(t, err)
+	require.Equal(t, "api_key=secret&format=json&page=1", req.URL.RawQuery)
+
+	// The request's URL and Query take precedence
+	req, err = c.BuildRequest(&Request{
+		Method: http.MethodGet,
+		URL:    "/users?format=xml",
+		Query:  map[string]string{"page": "2"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "format=xml&api_key=secret&page=2", req.URL.RawQuery)
+
+	// Clones don't share the default query
+	c2 := c.Clone(WithDefaultQuery("api_key", "other"))
+	require.Equal(t, "secret", c.DefaultQuery.Get("api_key"))
+	require.Equal(t, "other", c2.DefaultQuery.Get("api_key"))
+}
@@ -0,0 +1,117 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Paginate fetches a sequence of pages, starting with a GET request
+// to rawURL. Each response is passed to page and then to next, which
+// returns the URL of the following page or an empty string if there
+// are no more pages. The body is buffered before page is called so
+// that both functions can decode it. Paginate stops at the first
+// error, including when ctx is cancelled, and returns it.
+func (c *Client) Paginate(ctx context.Context, rawURL string, next func(rsp *Response) (string, error), page func(rsp *Response) error) error {
+	for rawURL != "" {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rsp, err := c.Send(&Request{
+			Ctx:    ctx,
+			Method: http.MethodGet,
+			URL:    rawURL,
+		}).Response()
+		if err != nil {
+			return err
+		}
+
+		if err := rsp.Buffer(); err != nil {
+			return err
+		}
+
+		if err := page(rsp); err != nil {
+			return err
+		}
+
+		rawURL, err = next(rsp)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NextLink returns the URL of the Link header with rel="next", as
+// described in RFC 8288, or an empty string if there isn't one.
+// Relative URLs are resolved against the URL of the response. It
+// can be passed to Paginate as the next function.
+func NextLink(rsp *Response) (string, error) {
+	for _, l := range parseLinks(rsp.Header.Values("Link")) {
+		for _, rel := range strings.Fields(l.params["rel"]) {
+			if !strings.EqualFold(rel, "next") {
+				continue
+			}
+
+			ref, err := url.Parse(l.url)
+			if err != nil {
+				return "", fmt.Errorf("invalid next link: %w", err)
+			}
+
+			base, err := url.Parse(rsp.FinalURL())
+			if err != nil {
+				return "", fmt.Errorf("invalid response URL: %w", err)
+			}
+
+			return base.ResolveReference(ref).String(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// link is a link from a Link header
+type link struct {
+	url    string
+	params map[string]string
+}
+
+// parseLinks parses the values of Link headers.
+// Each value can contain several comma-separated links.
+func parseLinks(values []string) []link {
+	var links []link
+
+	for _, v := range values {
+		s := strings.TrimSpace(v)
+		for strings.HasPrefix(s, "<") {
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				break
+			}
+
+			l := link{url: s[1:end], params: make(map[string]string)}
+			s = strings.TrimSpace(s[end+1:])
+
+			for strings.HasPrefix(s, ";") {
+				var key, value string
+				key, s = nextToken(strings.TrimSpace(s[1:]))
+				s = strings.TrimSpace(s)
+				if strings.HasPrefix(s, "=") {
+					value, s = nextValue(strings.TrimSpace(s[1:]))
+					s = strings.TrimSpace(s)
+				}
+
+				l.params[strings.ToLower(key)] = value
+			}
+
+			links = append(links, l)
+			s = strings.TrimSpace(strings.TrimPrefix(s, ","))
+		}
+	}
+
+	return links
+}
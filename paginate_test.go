@@ -0,0 +1,130 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Paginate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 3 {
+			w.Header().Add("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=3>; rel="last"`, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `[%d]`, page)
+	}))
+	defer srv.Close()
+
+	c := New(WithBaseURL(srv.URL))
+
+	var items []int
+	err := c.Paginate(context.Background(), "/items?page=1", NextLink, func(rsp *Response) error {
+		var page []int
+		if err := rsp.Decode(&page); err != nil {
+			return err
+		}
+		items = append(items, page...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, items)
+
+	// A cursor in the body
+	cursor := func(rsp *Response) (string, error) {
+		var page []int
+		if err := rsp.Decode(&page); err != nil {
+			return "", err
+		}
+		if page[0] >= 2 {
+			return "", nil
+		}
+		return fmt.Sprintf("/items?page=%d", page[0]+1), nil
+	}
+
+	items = nil
+	err = c.Paginate(context.Background(), "/items?page=1", cursor, func(rsp *Response) error {
+		items = append(items, 0)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	// An error from the page function stops pagination
+	errStop := errors.New("stop")
+	calls := 0
+	err = c.Paginate(context.Background(), "/items?page=1", NextLink, func(rsp *Response) error {
+		calls++
+		return errStop
+	})
+	require.Equal(t, errStop, err)
+	require.Equal(t, 1, calls)
+
+	// Cancelling the context stops pagination
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	err = c.Paginate(ctx, "/items?page=1", NextLink, func(rsp *Response) error {
+		calls++
+		cancel()
+		return nil
+	})
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Equal(t, 1, calls)
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		links []string
+		want  string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:  "absolute",
+			links: []string{`<https://api.example.com/items?page=2>; rel="next"`},
+			want:  "https://api.example.com/items?page=2",
+		},
+		{
+			name:  "relative",
+			links: []string{`</items?page=2>; rel=next`},
+			want:  "http://example.com/items?page=2",
+		},
+		{
+			name:  "several links",
+			links: []string{`</items?page=1>; rel="prev", </items?a=1,2>; title="Next page"; rel="NEXT"`},
+			want:  "http://example.com/items?a=1,2",
+		},
+		{
+			name:  "several headers",
+			links: []string{`</items?page=1>; rel="prev"`, `</items?page=3>; rel="next last"`},
+			want:  "http://example.com/items?page=3",
+		},
+		{
+			name:  "no next",
+			links: []string{`</items?page=1>; rel="prev"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := newTestResponse(http.StatusOK, "application/json", "")
+			rsp.requestURL = "http://example.com/items?page=2"
+			for _, l := range tt.links {
+				rsp.Header.Add("Link", l)
+			}
+
+			next, err := NextLink(rsp)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, next)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package patch
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// expandPath replaces {name} placeholders in the template with the
+// corresponding escaped values in params. It is an error for a
+// placeholder to have no value or for a value to have no placeholder.
+func expandPath(template string, params map[string]string) (string, error) {
+	var b strings.Builder
+	used := make(map[string]bool, len(params))
+
+	rest := template
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			b.WriteString(rest)
+			break
+		}
+
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unclosed path parameter in %q", template)
+		}
+		end += start
+
+		name := rest[start+1 : end]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing value for path parameter %q", name)
+		}
+		used[name] = true
+
+		b.WriteString(rest[:start])
+		b.WriteString(url.PathEscape(value))
+		rest = rest[end+1:]
+	}
+
+	for name := range params {
+		if !used[name] {
+			return "", fmt.Errorf("path parameter %q not found in %q", name, template)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// joinURL appends ref's path to base's path, preserving the base path
+// even if ref's path is absolute. If ref is an absolute URL, it is
+// returned unchanged.
+func joinURL(base, ref *url.URL) (*url.URL, error) {
+	if ref.IsAbs() || ref.Host != "" {
+		return ref, nil
+	}
+
+	u := *base
+	u.RawQuery = ref.RawQuery
+	u.Fragment = ref.Fragment
+
+	if ref.Path == "" {
+		return &u, nil
+	}
+
+	// Join the escaped paths so that escaped
+	// characters such as %2F are preserved.
+	joined := strings.TrimSuffix(base.EscapedPath(), "/") + "/" + strings.TrimPrefix(ref.EscapedPath(), "/")
+	p, err := url.Parse(joined)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path = p.Path
+	u.RawPath = p.RawPath
+
+	return &u, nil
+}
@@ -0,0 +1,102 @@
+package patch
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		params   map[string]string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "no params",
+			template: "/users",
+			want:     "/users",
+		},
+		{
+			name:     "multiple params",
+			template: "/users/{id}/posts/{postID}",
+			params:   map[string]string{"id": "5", "postID": "10"},
+			want:     "/users/5/posts/10",
+		},
+		{
+			name:     "escaped value",
+			template: "/files/{name}",
+			params:   map[string]string{"name": "a/b c?"},
+			want:     "/files/a%2Fb%20c%3F",
+		},
+		{
+			name:     "absolute URL",
+			template: "http://example.com/users/{id}?foo=bar",
+			params:   map[string]string{"id": "5"},
+			want:     "http://example.com/users/5?foo=bar",
+		},
+		{
+			name:     "missing value",
+			template: "/users/{id}",
+			params:   map[string]string{},
+			wantErr:  true,
+		},
+		{
+			name:     "unused value",
+			template: "/users/{id}",
+			params:   map[string]string{"id": "5", "foo": "bar"},
+			wantErr:  true,
+		},
+		{
+			name:     "unclosed placeholder",
+			template: "/users/{id",
+			params:   map[string]string{"id": "5"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandPath(tt.template, tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expandPath() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestJoinURL(t *testing.T) {
+	tests := []struct {
+		base string
+		ref  string
+		want string
+	}{
+		{"https://example.com/api/v1", "/users", "https://example.com/api/v1/users"},
+		{"https://example.com/api/v1/", "users", "https://example.com/api/v1/users"},
+		{"https://example.com/api/v1/", "/users/", "https://example.com/api/v1/users/"},
+		{"https://example.com", "/users", "https://example.com/users"},
+		{"https://example.com/api", "/users?page=2", "https://example.com/api/users?page=2"},
+		{"https://example.com/api", "?page=2", "https://example.com/api?page=2"},
+		{"https://example.com/api", "/files/a%2Fb", "https://example.com/api/files/a%2Fb"},
+		{"https://example.com/api", "https://other.com/users", "https://other.com/users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.base+" "+tt.ref, func(t *testing.T) {
+			base, err := url.Parse(tt.base)
+			require.NoError(t, err)
+			ref, err := url.Parse(tt.ref)
+			require.NoError(t, err)
+
+			got, err := joinURL(base, ref)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.String())
+		})
+	}
+}
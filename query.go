@@ -2,21 +2,31 @@ package patch
 
 import (
 	"net/url"
+	"strings"
 
 	"github.com/gorilla/schema"
 )
 
 func toQueryString(v interface{}, tagAlias string) (string, error) {
+	values, err := toURLValues(v, tagAlias)
+	if err != nil {
+		return "", err
+	}
+
+	return values.Encode(), nil
+}
+
+func toURLValues(v interface{}, tagAlias string) (url.Values, error) {
 	if v == nil {
-		return "", nil
+		return url.Values{}, nil
 	}
 
 	if m, ok := v.(url.Values); ok {
-		return m.Encode(), nil
+		return m, nil
 	}
 
 	if m, ok := v.(map[string][]string); ok {
-		return url.Values(m).Encode(), nil
+		return m, nil
 	}
 
 	if m, ok := v.(map[string]string); ok {
@@ -24,7 +34,7 @@ func toQueryString(v interface{}, tagAlias string) (string, error) {
 		for key, value := range m {
 			values.Set(key, value)
 		}
-		return values.Encode(), nil
+		return values, nil
 	}
 
 	// Note: gorilla/schema only supports structs
@@ -34,8 +44,125 @@ func toQueryString(v interface{}, tagAlias string) (string, error) {
 
 	values := url.Values{}
 	if err := e.Encode(v, values); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return values.Encode(), nil
+	return values, nil
+}
+
+// withQuery adds the values to the query string of rawURL. Values
+// replace any existing values with the same key, in the position of
+// the first of them. The rest of the query string is left as it is,
+// so that parameters the caller encoded (e.g. in a pre-signed URL)
+// are not reordered or re-escaped.
+func withQuery(rawURL string, values url.Values) string {
+	if len(values) == 0 {
+		return rawURL
+	}
+
+	base, query, fragment := splitQuery(rawURL)
+
+	var parts []string
+	replaced := make(map[string]bool)
+	for _, part := range splitQueryParts(query) {
+		key := queryKey(part)
+		if _, ok := values[key]; !ok {
+			parts = append(parts, part)
+			continue
+		}
+
+		if !replaced[key] {
+			replaced[key] = true
+			if enc := (url.Values{key: values[key]}).Encode(); enc != "" {
+				parts = append(parts, enc)
+			}
+		}
+	}
+
+	added := url.Values{}
+	for key, vs := range values {
+		if !replaced[key] {
+			added[key] = vs
+		}
+	}
+	if enc := added.Encode(); enc != "" {
+		parts = append(parts, enc)
+	}
+
+	if len(parts) == 0 {
+		return base + fragment
+	}
+
+	return base + "?" + strings.Join(parts, "&") + fragment
+}
+
+// splitQuery splits rawURL into the part before the query string,
+// the raw query string and the fragment including its leading "#"
+func splitQuery(rawURL string) (base, query, fragment string) {
+	base = rawURL
+	if i := strings.IndexByte(base, '#'); i >= 0 {
+		base, fragment = base[:i], base[i:]
+	}
+
+	if i := strings.IndexByte(base, '?'); i >= 0 {
+		base, query = base[:i], base[i+1:]
+	}
+
+	return base, query, fragment
+}
+
+// splitQueryParts splits a raw query string into its key=value pairs
+func splitQueryParts(query string) []string {
+	if query == "" {
+		return nil
+	}
+
+	return strings.Split(query, "&")
+}
+
+// queryKey returns the unescaped key of a raw key=value pair
+func queryKey(part string) string {
+	if i := strings.IndexByte(part, '='); i >= 0 {
+		part = part[:i]
+	}
+
+	if key, err := url.QueryUnescape(part); err == nil {
+		return key
+	}
+
+	return part
+}
+
+// withDefaultQuery adds the values to the query string of rawURL,
+// except for keys that are already in the query string. The existing
+// query string is left as it is and the defaults are appended to it.
+func withDefaultQuery(rawURL string, values url.Values) string {
+	if len(values) == 0 {
+		return rawURL
+	}
+
+	base, query, fragment := splitQuery(rawURL)
+
+	present := make(map[string]bool)
+	for _, part := range splitQueryParts(query) {
+		present[queryKey(part)] = true
+	}
+
+	missing := url.Values{}
+	for key, vs := range values {
+		if !present[key] {
+			missing[key] = vs
+		}
+	}
+
+	enc := missing.Encode()
+	if enc == "" {
+		return rawURL
+	}
+
+	if query != "" {
+		enc = query + "&" + enc
+	}
+
+	return base + "?" + enc + fragment
 }
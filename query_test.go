@@ -76,3 +76,113 @@ func TestToQueryString(t *testing.T) {
 		})
 	}
 }
+
+func TestWithQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		rawURL string
+		values url.Values
+		want   string
+	}{
+		{
+			name:   "no query",
+			rawURL: "http://example.com/users",
+			values: url.Values{"page": {"2"}},
+			want:   "http://example.com/users?page=2",
+		},
+		{
+			name:   "replaced in place",
+			rawURL: "http://example.com/users?z=1&page=1&a=2&page=3",
+			values: url.Values{"page": {"2"}},
+			want:   "http://example.com/users?z=1&page=2&a=2",
+		},
+		{
+			name:   "appended",
+			rawURL: "http://example.com/users?z=1&a=2",
+			values: url.Values{"page": {"2"}, "b": {"x y"}},
+			want:   "http://example.com/users?z=1&a=2&b=x+y&page=2",
+		},
+		{
+			name:   "escaping preserved",
+			rawURL: "http://example.com/f?X-Amz-Credential=AK%2F2020%2Fs3&A=1%20b&flag",
+			values: url.Values{"page": {"2"}},
+			want:   "http://example.com/f?X-Amz-Credential=AK%2F2020%2Fs3&A=1%20b&flag&page=2",
+		},
+		{
+			name:   "semicolons preserved",
+			rawURL: "http://example.com/users?a=1;b=2",
+			values: url.Values{"page": {"2"}},
+			want:   "http://example.com/users?a=1;b=2&page=2",
+		},
+		{
+			name:   "escaped key replaced",
+			rawURL: "/users?first%20name=a",
+			values: url.Values{"first name": {"b"}},
+			want:   "/users?first+name=b",
+		},
+		{
+			name:   "fragment",
+			rawURL: "/users?a=1#top",
+			values: url.Values{"page": {"2"}},
+			want:   "/users?a=1&page=2#top",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, withQuery(tt.rawURL, tt.values))
+		})
+	}
+}
+
+func TestWithDefaultQuery_rawQuery(t *testing.T) {
+	defaults := url.Values{"api_key": {"k"}, "format": {"json"}}
+
+	tests := []struct {
+		name   string
+		rawURL string
+		want   string
+	}{
+		{
+			name:   "no query",
+			rawURL: "https://h/p",
+			want:   "https://h/p?api_key=k&format=json",
+		},
+		{
+			name:   "order preserved",
+			rawURL: "https://h/p?z=1&a=2",
+			want:   "https://h/p?z=1&a=2&api_key=k&format=json",
+		},
+		{
+			name:   "existing key kept",
+			rawURL: "https://h/p?format=xml",
+			want:   "https://h/p?format=xml&api_key=k",
+		},
+		{
+			name:   "all keys present",
+			rawURL: "https://h/p?format=xml&api_key=other",
+			want:   "https://h/p?format=xml&api_key=other",
+		},
+		{
+			name:   "semicolons preserved",
+			rawURL: "https://h/p?a=1;b=2",
+			want:   "https://h/p?a=1;b=2&api_key=k&format=json",
+		},
+		{
+			name:   "pre-signed URL",
+			rawURL: "https://b.s3.amazonaws.com/k?X-Amz-Credential=AK%2F2020%2Fs3&X-Amz-Signature=abc&A=1%20b&flag",
+			want:   "https://b.s3.amazonaws.com/k?X-Amz-Credential=AK%2F2020%2Fs3&X-Amz-Signature=abc&A=1%20b&flag&api_key=k&format=json",
+		},
+		{
+			name:   "fragment",
+			rawURL: "https://h/p?a=1#top",
+			want:   "https://h/p?a=1&api_key=k&format=json#top",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, withDefaultQuery(tt.rawURL, defaults))
+		})
+	}
+}
@@ -1,35 +1,173 @@
 package patch
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // Request holds the information needed to make an HTTP request
 type Request struct {
-	Ctx     context.Context
-	Method  string
-	URL     string
+	// Ctx is the context of the request. If nil, the
+	// client's BaseContext or context.Background() is
+	// used. The client's timeout applies regardless
+	// of the context.
+	Ctx context.Context
+
+	Method string
+	URL    string
+
+	// Headers are sent with the request. Headers set by the
+	// client, such as the Content-Type, are only added if
+	// they are not already set here.
 	Headers http.Header
+
+	// Host, if set, is sent in the Host header instead of the host
+	// from the URL, e.g. to test virtual hosts behind a load balancer.
+	// Setting Host in Headers has no effect because net/http ignores it.
+	Host string
+
+	// AddHeaders are added to the request after all other headers
+	// have been set, including those set by the client. Values are
+	// appended to any existing values rather than replacing them.
+	AddHeaders http.Header
+
+	// Body is encoded using the request's Encoder or the client's
+	// DefaultEncoder. If Body is an io.Reader or []byte, it is sent
+	// as-is and the Content-Type header should be set in Headers,
+	// unless it is a []byte and the request has an Encoder or the
+	// client's DefaultEncoder is an EncoderText.
+	// If Body is a func() (io.Reader, error), it is called to get a
+	// fresh raw body each time one is needed, e.g. when net/http
+	// follows a 307 or 308 redirect. JSONPatchBody and MergePatchBody
+	// bodies are always encoded as JSON with their own Content-Type.
 	Body    interface{}
 	Encoder Encoder
+
+	// IdempotencyKey, if set, is sent in the Idempotency-Key header
+	// so that the server can detect and ignore duplicate requests,
+	// e.g. when a POST is resent after a network error.
+	IdempotencyKey string
+
+	// PathParams are substituted into {name}
+	// placeholders in the URL after being escaped.
+	PathParams map[string]string
+
+	// Query is added to the URL's query string, replacing any
+	// values with the same key. It can be a url.Values,
+	// map[string][]string, map[string]string or a struct with
+	// fields tagged `url:"name"`. It works with any method and
+	// is independent of the Body.
+	Query interface{}
+
+	// SkipStatusValidation disables the client's status
+	// and response validators for this request.
+	SkipStatusValidation bool
+
+	// Chunked sends the body using chunked transfer encoding
+	// without a Content-Length. This is useful for streaming a
+	// body whose length is not known up front. Encoded bodies
+	// are streamed from the encoder rather than buffered.
+	Chunked bool
+
+	// Trailers are sent after the body. Sending trailers requires
+	// chunked transfer encoding, so the Content-Length is not set.
+	Trailers http.Header
+}
+
+// SetIfNoneMatch sets the If-None-Match header so that the server
+// responds with 304 Not Modified if the resource's ETag matches.
+func (r *Request) SetIfNoneMatch(etag string) {
+	r.setHeader("If-None-Match", etag)
+}
+
+// SetIfModifiedSince sets the If-Modified-Since header so that the server
+// responds with 304 Not Modified if the resource hasn't changed since t.
+func (r *Request) SetIfModifiedSince(t time.Time) {
+	r.setHeader("If-Modified-Since", t.UTC().Format(http.TimeFormat))
 }
 
-func (r *Request) validate() error {
+func (r *Request) setHeader(key, value string) {
+	if r.Headers == nil {
+		r.Headers = http.Header{}
+	}
+
+	r.Headers.Set(key, value)
+}
+
+// validate checks the request. Methods in allowedMethods
+// are accepted in addition to the standard methods.
+func (r *Request) validate(allowedMethods []string) error {
 	switch {
-	case !validMethod(r.Method):
+	case !validMethod(r.Method) && !containsString(allowedMethods, r.Method):
 		return InvalidMethodError(r.Method)
 	}
 	return nil
 }
 
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// context returns the request's context or, if nil, the
+// base context. If both are nil, context.Background() is used.
+func (r *Request) context(base context.Context) context.Context {
+	if r.Ctx != nil {
+		return r.Ctx
+	}
+
+	if base != nil {
+		return base
+	}
+
+	return context.Background()
+}
+
+// bodyEncoder is a body that encodes itself and sets its own
+// Content-Type, e.g. JSONPatchBody, regardless of the encoder
+type bodyEncoder interface {
+	encodeBody() (io.Reader, string, error)
+}
+
 func (r *Request) prepareBody(defaultEncoder Encoder) (io.Reader, string, error) {
 	if r.Body == nil {
 		return nil, "", nil
 	}
 
+	// Raw bodies are sent as-is. The Content-Length is set by
+	// http.NewRequest for *bytes.Reader, *bytes.Buffer and
+	// *strings.Reader bodies.
+	switch body := r.Body.(type) {
+	case []byte:
+		// Byte slices are encoded if an encoder was set on the
+		// request or the client encodes text, so that the
+		// encoder's Content-Type is set.
+		if r.Encoder == nil && !isTextEncoder(defaultEncoder) {
+			return bytes.NewReader(body), "", nil
+		}
+	case io.Reader:
+		return body, "", nil
+	case func() (io.Reader, error):
+		reader, err := body()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get body: %w", err)
+		}
+		return reader, "", nil
+	case bodyEncoder:
+		return body.encodeBody()
+	}
+
 	enc := r.Encoder
 	if enc == nil {
 		enc = defaultEncoder
@@ -39,14 +177,42 @@ func (r *Request) prepareBody(defaultEncoder Encoder) (io.Reader, string, error)
 		return nil, "", fmt.Errorf("request has body but no encoder set on client or request")
 	}
 
+	if f, ok := enc.(EncoderFactory); ok {
+		enc = f.NewEncoder()
+	}
+
 	reader, err := enc.Encode(r.Body)
 	if err != nil {
 		return nil, "", err
 	}
 
+	// Make sure the Content-Length is set by http.NewRequest. Encoded
+	// bodies are expected to be small enough to hold in memory, and
+	// some servers reject chunked requests.
+	if !r.Chunked {
+		switch reader.(type) {
+		case *bytes.Reader, *bytes.Buffer, *strings.Reader:
+		default:
+			b, err := ioutil.ReadAll(reader)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to read encoded body: %w", err)
+			}
+			reader = bytes.NewReader(b)
+		}
+	}
+
 	return reader, enc.ContentType(), nil
 }
 
+func isTextEncoder(enc Encoder) bool {
+	switch enc.(type) {
+	case EncoderText, *EncoderText:
+		return true
+	}
+
+	return false
+}
+
 func validMethod(method string) bool {
 	switch method {
 	case http.MethodGet:
@@ -64,3 +230,58 @@ func validMethod(method string) bool {
 
 	return true
 }
+
+// getBody returns a function that creates a new copy of the
+// body, if the body was provided as a function.
+func (r *Request) getBody() func() (io.ReadCloser, error) {
+	fn, ok := r.Body.(func() (io.Reader, error))
+	if !ok {
+		return nil
+	}
+
+	return func() (io.ReadCloser, error) {
+		reader, err := fn()
+		if err != nil {
+			return nil, err
+		}
+
+		if rc, ok := reader.(io.ReadCloser); ok {
+			return rc, nil
+		}
+
+		return ioutil.NopCloser(reader), nil
+	}
+}
+
+// readBody returns the request body without consuming it.
+// If the body cannot be re-read using GetBody, it is buffered.
+func readBody(req *http.Request) ([]byte, error) {
+	var body io.Reader = http.NoBody
+
+	switch {
+	case req.GetBody != nil:
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = rc.Close() }()
+		body = rc
+
+	case req.Body != nil:
+		b, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		return b, nil
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+
+	return b, nil
+}
@@ -2,14 +2,72 @@ package patch
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Response represents the response from a request
 type Response struct {
 	*http.Response
+
+	// Duration is the time taken for the base client to return
+	// the response. It does not include reading the body.
+	Duration time.Duration
+
+	// client is the client that made the request. It
+	// is nil if the Response was constructed manually.
+	client *Client
+
+	// requestURL is the URL of the request before redirects
+	requestURL string
+
+	// requestID is the value of the client's RequestIDHeader
+	requestID string
+}
+
+// RequestURL returns the URL that the request was sent to, after
+// resolving it against the client's BaseURL but before any redirects.
+func (r *Response) RequestURL() string {
+	if r.requestURL == "" && r.Request != nil {
+		return r.Request.URL.String()
+	}
+
+	return r.requestURL
+}
+
+// RequestID returns the ID sent in the client's RequestIDHeader,
+// or an empty string if the client doesn't have one set.
+func (r *Response) RequestID() string {
+	return r.requestID
+}
+
+// FinalURL returns the URL of the request that produced this response,
+// which differs from RequestURL if any redirects were followed.
+func (r *Response) FinalURL() string {
+	if r.Request == nil {
+		return r.requestURL
+	}
+
+	return r.Request.URL.String()
+}
+
+// Buffer reads the whole body into memory and closes the underlying
+// body. Afterwards, BodyBytes, BodyString and the Decode methods can
+// be called any number of times, in any order. The body is held in
+// memory for the lifetime of the Response, so avoid buffering very
+// large bodies. Note that BodyBytes and the Decode methods buffer
+// the body implicitly.
+func (r *Response) Buffer() error {
+	_, err := r.BodyBytes()
+	return err
 }
 
 // BodyBytes returns the body as a byte slice
@@ -18,28 +76,248 @@ func (r *Response) BodyBytes() ([]byte, error) {
 	case *bufCloser:
 		return rc.Bytes(), nil
 
+	case *errCloser:
+		return nil, rc.err
+
 	default:
 		defer func() { _ = rc.Close() }()
 
+		// Read at most one byte more than the limit
+		// so that we know if the limit was exceeded.
+		var reader io.Reader = rc
+		limit := r.maxBytes()
+		if limit > 0 {
+			reader = io.LimitReader(rc, limit+1)
+		}
+
+		// Read into a pooled buffer so that the growth
+		// allocations are amortised across responses.
+		tmp := bufPool.Get().(*bytes.Buffer)
+		defer putBuffer(tmp)
+		tmp.Reset()
+
+		_, err := tmp.ReadFrom(reader)
+		if err == nil && limit > 0 && int64(tmp.Len()) > limit {
+			r.Body = &errCloser{err: ErrResponseTooLarge}
+			return nil, ErrResponseTooLarge
+		}
+
+		if r.isShort(int64(tmp.Len()), err) {
+			err = fmt.Errorf("%w: read %d of %d bytes", ErrShortBody, tmp.Len(), r.ContentLength)
+			r.Body = &errCloser{err: err}
+			return nil, err
+		}
+
+		// Copy the body out of the pooled buffer, because the
+		// returned slice is retained and exposed to the caller.
+		b := make([]byte, tmp.Len())
+		copy(b, tmp.Bytes())
+
 		// Replace the response body with a bufCloser
-		buf := &bufCloser{}
-		r.Body = buf
+		r.Body = newBufCloser(b)
+
+		return b, err
+	}
+}
+
+// Drain reads the rest of the body and closes it so that the
+// underlying connection can be reused. At most maxDrainBytes are
+// read, because closing the connection is cheaper than reading a
+// large body. A buffered body can still be read afterwards.
+func (r *Response) Drain() error {
+	switch r.Body.(type) {
+	case *bufCloser, *errCloser:
+		return nil
+	}
 
-		// Use a TeeReader to read the body while
-		// simultaneously piping it into the buffer
-		tr := io.TeeReader(rc, buf)
-		return ioutil.ReadAll(tr)
+	_, err := io.Copy(ioutil.Discard, io.LimitReader(r.Body, maxDrainBytes))
+	if closeErr := r.Body.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// maxDrainBytes is the maximum number of bytes read by Drain
+const maxDrainBytes = 256 << 10
+
+// RawBody returns the body for streaming, without buffering it.
+// It can only be read once, and MaxResponseBytes is not enforced.
+// Mixing RawBody with BodyBytes, BodyString or the Decode methods
+// is not supported, unless the body was buffered first, in which
+// case RawBody returns a reader over the buffered body.
+func (r *Response) RawBody() io.ReadCloser {
+	return r.streamBody()
+}
+
+// bufPool holds buffers used to read response bodies
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// maxPooledBufferSize is the capacity above which buffers are
+// not returned to the pool, so that a single large response
+// doesn't keep a large amount of memory alive.
+const maxPooledBufferSize = 1 << 20
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() <= maxPooledBufferSize {
+		bufPool.Put(b)
 	}
 }
 
+// isShort returns true if the body ended before the
+// declared Content-Length, given the number of bytes read
+// and the error that reading the body returned.
+func (r *Response) isShort(n int64, err error) bool {
+	if r.ContentLength <= 0 || n >= r.ContentLength {
+		return false
+	}
+
+	// HEAD, 204 and 304 responses can declare
+	// a Content-Length without having a body.
+	if r.Request != nil && r.Request.Method == http.MethodHead {
+		return false
+	}
+
+	switch r.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+
+	// The http.Client returns io.ErrUnexpectedEOF if the
+	// connection is closed before the end of the body.
+	return err == nil || err == io.ErrUnexpectedEOF
+}
+
+// maxBytes returns the maximum body size or 0 if there is no limit
+func (r *Response) maxBytes() int64 {
+	if r.client == nil {
+		return 0
+	}
+
+	return r.client.MaxResponseBytes
+}
+
 // BodyString returns the body as a string
 func (r *Response) BodyString() (string, error) {
 	b, err := r.BodyBytes()
 	return string(b), err
 }
 
+// NotModified returns true if the status code is 304 Not Modified
+func (r *Response) NotModified() bool {
+	return r.StatusCode == http.StatusNotModified
+}
+
+// AsError returns nil for 2xx responses. For other responses, it passes
+// the body to the decode function and returns the error it builds. If
+// the decode function returns nil, a BadStatusError is returned instead.
+func (r *Response) AsError(decode func([]byte) error) error {
+	if r.StatusCode >= 200 && r.StatusCode < 300 {
+		return nil
+	}
+
+	body, err := r.BodyBytes()
+	if err != nil {
+		return err
+	}
+
+	if err := decode(body); err != nil {
+		return err
+	}
+
+	return BadStatusError(r.StatusCode)
+}
+
+// Trailers reads the whole body and then returns the trailers sent
+// by the server after the body. Trailers are only available once
+// the body has been read, which is why this may return an error.
+func (r *Response) Trailers() (http.Header, error) {
+	if _, err := r.BodyBytes(); err != nil {
+		return nil, err
+	}
+
+	return r.Trailer, nil
+}
+
+// Cookie returns the named cookie set by the response's Set-Cookie
+// headers. If the cookie is not found, http.ErrNoCookie is returned.
+func (r *Response) Cookie(name string) (*http.Cookie, error) {
+	for _, c := range r.Cookies() {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+
+	return nil, http.ErrNoCookie
+}
+
+// CookieValue returns the value of the named cookie
+// or an empty string if the cookie is not found.
+func (r *Response) CookieValue(name string) string {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
+
+// LastModified parses the Last-Modified header
+func (r *Response) LastModified() (time.Time, error) {
+	v := r.Header.Get("Last-Modified")
+	if v == "" {
+		return time.Time{}, fmt.Errorf("response has no Last-Modified header")
+	}
+
+	return http.ParseTime(v)
+}
+
+// RetryAfter parses the Retry-After header, which can be a number
+// of seconds or an HTTP date. A date in the past results in zero.
+func (r *Response) RetryAfter() (time.Duration, error) {
+	v := r.Header.Get("Retry-After")
+	if v == "" {
+		return 0, fmt.Errorf("response has no Retry-After header")
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("invalid Retry-After header %q", v)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Retry-After header %q", v)
+	}
+
+	if d := time.Until(t); d > 0 {
+		return d, nil
+	}
+
+	return 0, nil
+}
+
 type DecodeHook func(status int) interface{}
 
+// RawTarget is a decode target that receives the body without
+// it going through the decoder. Use Raw to create one.
+type RawTarget struct {
+	v interface{}
+}
+
+// Raw wraps a *string or *[]byte so that it receives the body as-is
+// when passed to Decode or DecodeUsing alongside other targets, e.g.
+// to decode the body and keep a copy of it in a single call.
+func Raw(v interface{}) RawTarget {
+	return RawTarget{v: v}
+}
+
 func On2xx(v interface{}) DecodeHook {
 	return func(status int) interface{} {
 		if status >= 200 && status < 300 {
@@ -91,7 +369,150 @@ func OnStatus(status int, v interface{}) DecodeHook {
 }
 
 func (r *Response) Decode(targets ...interface{}) error {
-	dec, err := inferDecoder(r.Header.Get("Content-Type"))
+	_, err := r.DecodeCount(targets...)
+	return err
+}
+
+// DecodeCount is like Decode but also returns the number of targets that
+// were decoded into. Targets are skipped if they are nil or a DecodeHook
+// that doesn't match the status, and all targets are skipped if the body
+// is empty. This can be used to detect that no hook matched the status.
+func (r *Response) DecodeCount(targets ...interface{}) (int, error) {
+	// Don't try to infer a decoder for an empty body or a
+	// 304 response because it probably has no Content-Type.
+	body, err := r.BodyBytes()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(body) == 0 || r.NotModified() {
+		return 0, nil
+	}
+
+	dec, err := r.inferDecoder()
+	if err != nil {
+		return 0, err
+	}
+
+	return r.decodeUsing(dec, targets...)
+}
+
+// DecodeSelecting decodes the body into the target of the first hook that
+// matches the response's status code, and returns that target. The decoder
+// is inferred from the Content-Type header. If no hook matches, the body
+// is not decoded and nil is returned.
+func (r *Response) DecodeSelecting(hooks ...DecodeHook) (interface{}, error) {
+	for _, hook := range hooks {
+		target := hook(r.StatusCode)
+		if target == nil {
+			continue
+		}
+
+		return target, r.Decode(target)
+	}
+
+	return nil, nil
+}
+
+// DecodeByStatus decodes the body into the target in m whose key matches
+// the response's status code, and returns the key. Keys can be exact
+// status codes (e.g. "404"), status classes (e.g. "4xx") or "default".
+// Exact codes take precedence over classes, which take precedence over
+// the default. If nothing matches, the body is not decoded and an empty
+// key is returned.
+func (r *Response) DecodeByStatus(m map[string]interface{}) (string, error) {
+	keys := []string{
+		strconv.Itoa(r.StatusCode),
+		fmt.Sprintf("%dxx", r.StatusCode/100),
+		"default",
+	}
+
+	for _, key := range keys {
+		if target, ok := m[key]; ok {
+			return key, r.Decode(target)
+		}
+	}
+
+	return "", nil
+}
+
+// UnmarshalByContentType decodes the body into the target in m whose key
+// matches the response's Content-Type, and returns the key. This is useful
+// for endpoints that negotiate the content type and may return one of
+// several formats. Keys are media types such as "application/json", which
+// also matches structured syntax suffixes such as application/vnd.api+json,
+// or wildcards such as "application/*" and "*/*". The most specific key
+// takes precedence. If nothing matches, the body is not decoded and an
+// empty key is returned.
+func (r *Response) UnmarshalByContentType(m map[string]interface{}) (string, error) {
+	mt := mediaType(r.contentType())
+
+	targets := make(map[string]string, len(m))
+	for key := range m {
+		targets[mediaType(key)] = key
+	}
+
+	candidates := []string{mt}
+	if base := suffixType(mt); base != "" {
+		candidates = append(candidates, base)
+	}
+	if slash := strings.IndexByte(mt, '/'); slash >= 0 {
+		candidates = append(candidates, mt[:slash+1]+"*")
+	}
+	candidates = append(candidates, "*/*")
+
+	for _, candidate := range candidates {
+		key, ok := targets[candidate]
+		if !ok {
+			continue
+		}
+
+		// Wildcards use the decoder for the actual content type
+		contentType := candidate
+		if strings.HasSuffix(candidate, "*") {
+			contentType = mt
+		}
+
+		// Fall back to the decoder for the structured syntax suffix
+		dec, err := inferDecoder(contentType, r.decoders())
+		if base := suffixType(contentType); err != nil && base != "" {
+			dec, err = inferDecoder(base, r.decoders())
+		}
+		if err != nil {
+			return key, ContentTypeError(contentType)
+		}
+
+		return key, r.DecodeUsing(dec, m[key])
+	}
+
+	return "", nil
+}
+
+// suffixType returns the media type implied by the structured syntax
+// suffix of mt, e.g. application/json for application/vnd.api+json,
+// or an empty string if mt doesn't have a suffix.
+func suffixType(mt string) string {
+	slash, plus := strings.IndexByte(mt, '/'), strings.LastIndexByte(mt, '+')
+	if slash < 0 || plus < slash {
+		return ""
+	}
+
+	return mt[:slash+1] + mt[plus+1:]
+}
+
+// DecodeJSON decodes the body as JSON, regardless of the Content-Type
+// header. If a decoder has been registered on the client for
+// application/json, it is used instead of the default JSON decoder.
+func (r *Response) DecodeJSON(targets ...interface{}) error {
+	return r.DecodeAs("application/json", targets...)
+}
+
+// DecodeAs decodes the body using the decoder for the given content
+// type instead of the response's Content-Type header. This is useful
+// for servers that send the wrong Content-Type. Decoders registered
+// on the client take precedence over the built-in decoders.
+func (r *Response) DecodeAs(contentType string, targets ...interface{}) error {
+	dec, err := inferDecoder(contentType, r.decoders())
 	if err != nil {
 		return err
 	}
@@ -99,17 +520,112 @@ func (r *Response) Decode(targets ...interface{}) error {
 	return r.DecodeUsing(dec, targets...)
 }
 
-func (r *Response) DecodeJSON(targets ...interface{}) error {
-	return r.DecodeUsing(jsonDecoder, targets...)
+// DecodeKeys decodes the body as JSON and then decodes the value of
+// each top-level key in m into the key's target. This avoids defining
+// a wrapper struct just to split out nested objects, e.g. a payload
+// and its pagination metadata. Targets whose key is missing from the
+// body are left untouched.
+func (r *Response) DecodeKeys(m map[string]interface{}) error {
+	dec, err := inferDecoder("application/json", r.decoders())
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := r.DecodeUsing(dec, &raw); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok := raw[key]
+		if !ok {
+			continue
+		}
+
+		if err := dec.Decode(value, m[key]); err != nil {
+			return fmt.Errorf("key %q: %w", key, newDecodeError(r, dec, value, err))
+		}
+	}
+
+	return nil
 }
 
-// DecodeUsing decodes the response into the receivers using the given Decoder
+// DecodeJSON decodes the body as JSON into a new value of type T,
+// regardless of the Content-Type header.
+func DecodeJSON[T any](r *Response) (T, error) {
+	var v T
+	err := r.DecodeJSON(&v)
+	return v, err
+}
+
+// BodyMap decodes the body into a generic map, which is handy for
+// exploring unknown APIs. The decoder is inferred from the Content-Type
+// header. If the Content-Type is missing, unsupported or text/plain,
+// the body is decoded as JSON. An empty body results in a nil map.
+func (r *Response) BodyMap() (map[string]interface{}, error) {
+	var m map[string]interface{}
+
+	dec, err := r.inferDecoder()
+	if err != nil || dec == textDecoder {
+		return m, r.DecodeJSON(&m)
+	}
+
+	return m, r.DecodeUsing(dec, &m)
+}
+
+// DecodeYAML decodes the body as YAML, regardless of the Content-Type header
+func (r *Response) DecodeYAML(targets ...interface{}) error {
+	return r.DecodeUsing(yamlDecoder, targets...)
+}
+
+// DecodeMsgpack decodes the body as MessagePack, regardless of the Content-Type header
+func (r *Response) DecodeMsgpack(targets ...interface{}) error {
+	return r.DecodeUsing(msgpackDecoder, targets...)
+}
+
+// DecodeUsing decodes the response into the receivers using the given Decoder.
+// If the body is empty or the status is 304 Not Modified, the receivers
+// are left untouched. Receivers wrapped with Raw are given the body without
+// decoding, after transcoding it to UTF-8 if necessary and applying the
+// client's BodyTransformer.
 func (r *Response) DecodeUsing(dec Decoder, targets ...interface{}) error {
+	_, err := r.decodeUsing(dec, targets...)
+	return err
+}
+
+// decodeUsing decodes the body into the targets and
+// returns the number of targets that were decoded into
+func (r *Response) decodeUsing(dec Decoder, targets ...interface{}) (int, error) {
 	body, err := r.BodyBytes()
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	// An empty body (e.g. from a 204 No Content response)
+	// or a 304 Not Modified response leaves the targets untouched.
+	if len(body) == 0 || r.NotModified() {
+		return 0, nil
+	}
+
+	// Transcode the body if the Content-Type declares a charset other than UTF-8
+	body, err = toUTF8(body, r.contentType())
+	if err != nil {
+		return 0, err
 	}
 
+	if r.client != nil && r.client.BodyTransformer != nil {
+		if body, err = r.client.BodyTransformer(body); err != nil {
+			return 0, fmt.Errorf("failed to transform body: %w", err)
+		}
+	}
+
+	n := 0
 	for _, receiver := range targets {
 		switch v := receiver.(type) {
 		case DecodeHook:
@@ -120,19 +636,161 @@ func (r *Response) DecodeUsing(dec Decoder, targets ...interface{}) error {
 			continue
 		}
 
+		// Raw targets receive the body as-is, so that
+		// it can be kept alongside the decoded value.
+		if raw, ok := receiver.(RawTarget); ok {
+			if err := textDecoder.Decode(body, raw.v); err != nil {
+				return n, err
+			}
+			n++
+			continue
+		}
+
 		if err := dec.Decode(body, receiver); err != nil {
+			return n, newDecodeError(r, dec, body, err)
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// NDJSON reads a stream of newline-delimited JSON records from the body
+// and calls fn for each record. The body is read incrementally and is
+// not buffered, so it cannot be read again afterwards. Reading stops
+// if fn returns an error, which is then returned.
+func (r *Response) NDJSON(fn func(raw json.RawMessage) error) error {
+	body := r.streamBody()
+	defer func() { _ = body.Close() }()
+
+	dec := json.NewDecoder(body)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode NDJSON record: %w", err)
+		}
+
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+}
+
+// DecodeArray reads a JSON array from the body one element at a time
+// and calls fn for each element. fn is given a function that decodes
+// the element into v; if it isn't called, the element is skipped. Like
+// NDJSON, the body is read incrementally and is not buffered, so large
+// arrays can be processed in bounded memory. Reading stops if fn returns
+// an error, which is then returned. An empty body is not an error.
+func (r *Response) DecodeArray(fn func(decode func(v interface{}) error) error) error {
+	body := r.streamBody()
+	defer func() { _ = body.Close() }()
+
+	dec := json.NewDecoder(body)
+	if tok, err := dec.Token(); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to decode JSON array: %w", err)
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("failed to decode JSON array: unexpected %v", tok)
+	}
+
+	for dec.More() {
+		decoded := false
+		decode := func(v interface{}) error {
+			if decoded {
+				return fmt.Errorf("array element already decoded")
+			}
+
+			decoded = true
+			if err := dec.Decode(v); err != nil {
+				return fmt.Errorf("failed to decode array element: %w", err)
+			}
+
+			return nil
+		}
+
+		if err := fn(decode); err != nil {
 			return err
 		}
+
+		// Skip the element if fn didn't decode it
+		if !decoded {
+			if err := decode(&json.RawMessage{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("failed to decode JSON array: %w", err)
 	}
 
 	return nil
 }
 
+// streamBody returns a reader over the body that does not
+// consume the buffer if the body has already been buffered.
+func (r *Response) streamBody() io.ReadCloser {
+	if buf, ok := r.Body.(*bufCloser); ok {
+		return ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
+	}
+
+	return r.Body
+}
+
+// inferDecoder returns the decoder for the response's Content-Type
+func (r *Response) inferDecoder() (Decoder, error) {
+	return inferDecoder(r.contentType(), r.decoders())
+}
+
+// contentType returns the response's Content-Type header or, if
+// it is missing, the client's DefaultResponseContentType
+func (r *Response) contentType() string {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" && r.client != nil {
+		return r.client.DefaultResponseContentType
+	}
+
+	return ct
+}
+
+// decoders returns the decoders registered on the client
+func (r *Response) decoders() map[string]Decoder {
+	if r.client == nil {
+		return nil
+	}
+
+	return r.client.decoders
+}
+
 type bufCloser struct {
 	bytes.Buffer
 }
 
+// newBufCloser returns a bufCloser that takes ownership of b
+func newBufCloser(b []byte) *bufCloser {
+	return &bufCloser{Buffer: *bytes.NewBuffer(b)}
+}
+
 // Close is a no-op
 func (b *bufCloser) Close() error {
 	return nil
 }
+
+// errCloser is a body that could not be read
+type errCloser struct {
+	err error
+}
+
+// Read returns the error
+func (e *errCloser) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+// Close is a no-op
+func (e *errCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,632 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResponse(status int, contentType, body string) *Response {
+	return &Response{Response: &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}}
+}
+
+type testResult struct {
+	Result string `json:"result"`
+}
+
+type testError struct {
+	Message string `json:"message"`
+}
+
+func TestResponse_DecodeSelecting(t *testing.T) {
+	var result testResult
+	var apiErr testError
+
+	rsp := newTestResponse(http.StatusNotFound, "application/json", `{"message": "not found"}`)
+	got, err := rsp.DecodeSelecting(On2xx(&result), On4xx(&apiErr))
+	require.NoError(t, err)
+	require.Equal(t, &apiErr, got)
+	require.Equal(t, "not found", apiErr.Message)
+	require.Equal(t, "", result.Result)
+
+	rsp = newTestResponse(http.StatusOK, "application/json", `{"result": "ok"}`)
+	got, err = rsp.DecodeSelecting(On2xx(&result), On4xx(&apiErr))
+	require.NoError(t, err)
+	require.Equal(t, &result, got)
+	require.Equal(t, "ok", result.Result)
+
+	rsp = newTestResponse(http.StatusInternalServerError, "application/json", `{}`)
+	got, err = rsp.DecodeSelecting(On2xx(&result), On4xx(&apiErr))
+	require.NoError(t, err)
+	require.Nil(t, got)
+}
+
+func TestResponse_maxBytes(t *testing.T) {
+	c := New(WithMaxResponseBytes(5))
+
+	rsp := newTestResponse(http.StatusOK, "text/plain", "hello")
+	rsp.client = c
+	s, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "hello", s)
+
+	rsp = newTestResponse(http.StatusOK, "text/plain", "hello world")
+	rsp.client = c
+	_, err = rsp.BodyBytes()
+	require.True(t, errors.Is(err, ErrResponseTooLarge))
+
+	// Subsequent reads return the same error
+	var v string
+	err = rsp.Decode(&v)
+	require.True(t, errors.Is(err, ErrResponseTooLarge))
+}
+
+func TestResponse_Buffer(t *testing.T) {
+	rsp := newTestResponse(http.StatusOK, "application/json", `{"result": "ok"}`)
+	require.NoError(t, rsp.Buffer())
+
+	for i := 0; i < 2; i++ {
+		var v testResult
+		require.NoError(t, rsp.Decode(&v))
+		require.Equal(t, "ok", v.Result)
+
+		s, err := rsp.BodyString()
+		require.NoError(t, err)
+		require.Equal(t, `{"result": "ok"}`, s)
+	}
+}
+
+func TestResponse_emptyBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+	}{
+		{
+			name:        "204 with content type",
+			status:      http.StatusNoContent,
+			contentType: "application/json",
+		},
+		{
+			name:   "204 without content type",
+			status: http.StatusNoContent,
+		},
+		{
+			name:   "200 without content type",
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := testResult{Result: "unchanged"}
+
+			rsp := newTestResponse(tt.status, tt.contentType, "")
+			require.NoError(t, rsp.Decode(&v))
+			require.NoError(t, rsp.DecodeJSON(&v))
+			require.Equal(t, "unchanged", v.Result)
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	rsp := newTestResponse(http.StatusOK, "text/html", `{"result": "ok"}`)
+	v, err := DecodeJSON[testResult](rsp)
+	require.NoError(t, err)
+	require.Equal(t, testResult{Result: "ok"}, v)
+
+	rsp = newTestResponse(http.StatusOK, "application/json", `[1, 2, 3]`)
+	s, err := DecodeJSON[[]int](rsp)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, s)
+}
+
+func TestResponse_NDJSON(t *testing.T) {
+	body := "{\"result\": \"a\"}\n{\"result\": \"b\"}\n\n{\"result\": \"c\"}\n"
+
+	var got []string
+	rsp := newTestResponse(http.StatusOK, "application/x-ndjson", body)
+	err := rsp.NDJSON(func(raw json.RawMessage) error {
+		var v testResult
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		got = append(got, v.Result)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, got)
+
+	// Errors from the callback stop reading
+	stop := errors.New("stop")
+	calls := 0
+	rsp = newTestResponse(http.StatusOK, "application/x-ndjson", body)
+	err = rsp.NDJSON(func(raw json.RawMessage) error {
+		calls++
+		return stop
+	})
+	require.True(t, errors.Is(err, stop))
+	require.Equal(t, 1, calls)
+
+	// Invalid records return an error
+	rsp = newTestResponse(http.StatusOK, "application/x-ndjson", "{\"result\": \"a\"}\n{nope}\n")
+	err = rsp.NDJSON(func(raw json.RawMessage) error { return nil })
+	require.Error(t, err)
+}
+
+func TestResponse_DecodeArray(t *testing.T) {
+	body := `[{"result": "a"}, {"result": "b"}, {"result": "c"}]`
+
+	var got []string
+	rsp := newTestResponse(http.StatusOK, "application/json", body)
+	err := rsp.DecodeArray(func(decode func(v interface{}) error) error {
+		var v testResult
+		if err := decode(&v); err != nil {
+			return err
+		}
+		got = append(got, v.Result)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, got)
+
+	// Elements that aren't decoded are skipped
+	calls := 0
+	rsp = newTestResponse(http.StatusOK, "application/json", body)
+	err = rsp.DecodeArray(func(decode func(v interface{}) error) error {
+		calls++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	// Errors from the callback stop reading
+	stop := errors.New("stop")
+	calls = 0
+	rsp = newTestResponse(http.StatusOK, "application/json", body)
+	err = rsp.DecodeArray(func(decode func(v interface{}) error) error {
+		calls++
+		return stop
+	})
+	require.True(t, errors.Is(err, stop))
+	require.Equal(t, 1, calls)
+
+	// An empty body is not an error
+	rsp = newTestResponse(http.StatusNoContent, "", "")
+	require.NoError(t, rsp.DecodeArray(func(decode func(v interface{}) error) error {
+		t.Fatal("unexpected element")
+		return nil
+	}))
+
+	// Bodies that aren't arrays return an error
+	for _, body := range []string{`{"result": "a"}`, `[{"result": "a"}, {nope}]`, `[{"result": "a"}`} {
+		rsp = newTestResponse(http.StatusOK, "application/json", body)
+		err = rsp.DecodeArray(func(decode func(v interface{}) error) error {
+			var v testResult
+			return decode(&v)
+		})
+		require.Error(t, err, body)
+	}
+}
+
+func BenchmarkResponse_Decode(b *testing.B) {
+	body := `{"result": "` + strings.Repeat("a", 64<<10) + `"}`
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var v testResult
+		rsp := newTestResponse(http.StatusOK, "application/json", body)
+		if err := rsp.Decode(&v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestResponse_DecodeByStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		m       map[string]interface{}
+		wantKey string
+	}{
+		{
+			name:    "exact",
+			status:  http.StatusNotFound,
+			m:       map[string]interface{}{"404": &testError{}, "4xx": &testError{}},
+			wantKey: "404",
+		},
+		{
+			name:    "class",
+			status:  http.StatusBadRequest,
+			m:       map[string]interface{}{"2xx": &testResult{}, "4xx": &testError{}},
+			wantKey: "4xx",
+		},
+		{
+			name:    "default",
+			status:  http.StatusInternalServerError,
+			m:       map[string]interface{}{"2xx": &testResult{}, "default": &testError{}},
+			wantKey: "default",
+		},
+		{
+			name:    "no match",
+			status:  http.StatusInternalServerError,
+			m:       map[string]interface{}{"2xx": &testResult{}},
+			wantKey: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := newTestResponse(tt.status, "application/json", `{"message": "oops"}`)
+			key, err := rsp.DecodeByStatus(tt.m)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantKey, key)
+
+			if key != "" {
+				require.Equal(t, &testError{Message: "oops"}, tt.m[key])
+			}
+		})
+	}
+}
+
+func (e *testError) Error() string {
+	return e.Message
+}
+
+func TestResponse_AsError(t *testing.T) {
+	decode := func(body []byte) error {
+		var apiErr testError
+		if err := json.Unmarshal(body, &apiErr); err != nil {
+			return err
+		}
+		if apiErr.Message == "" {
+			return nil
+		}
+		return &apiErr
+	}
+
+	rsp := newTestResponse(http.StatusOK, "application/json", `{"message": "ignored"}`)
+	require.NoError(t, rsp.AsError(decode))
+
+	rsp = newTestResponse(http.StatusBadRequest, "application/json", `{"message": "invalid"}`)
+	err := rsp.AsError(decode)
+	var apiErr *testError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, "invalid", apiErr.Message)
+
+	rsp = newTestResponse(http.StatusBadGateway, "application/json", `{}`)
+	err = rsp.AsError(decode)
+	var statusErr BadStatusError
+	require.True(t, errors.As(err, &statusErr))
+	require.Equal(t, BadStatusError(http.StatusBadGateway), statusErr)
+}
+
+func TestResponse_charset(t *testing.T) {
+	rsp := newTestResponse(http.StatusOK, "text/plain; charset=ISO-8859-1", "caf\xe9")
+
+	var s string
+	require.NoError(t, rsp.Decode(&s))
+	require.Equal(t, "café", s)
+}
+
+func TestResponse_LastModified(t *testing.T) {
+	rsp := newTestResponse(http.StatusOK, "", "")
+	_, err := rsp.LastModified()
+	require.Error(t, err)
+
+	rsp.Header.Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+	lastModified, err := rsp.LastModified()
+	require.NoError(t, err)
+	require.True(t, time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC).Equal(lastModified))
+}
+
+func TestResponse_RetryAfter(t *testing.T) {
+	rsp := newTestResponse(http.StatusServiceUnavailable, "", "")
+	_, err := rsp.RetryAfter()
+	require.Error(t, err)
+
+	rsp.Header.Set("Retry-After", "120")
+	d, err := rsp.RetryAfter()
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Minute, d)
+
+	rsp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	d, err = rsp.RetryAfter()
+	require.NoError(t, err)
+	require.InDelta(t, float64(time.Hour), float64(d), float64(2*time.Second))
+
+	rsp.Header.Set("Retry-After", "Wed, 21 Oct 2015 07:28:00 GMT")
+	d, err = rsp.RetryAfter()
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), d)
+
+	rsp.Header.Set("Retry-After", "soon")
+	_, err = rsp.RetryAfter()
+	require.Error(t, err)
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestResponse_Drain(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("hello")}
+	rsp := &Response{Response: &http.Response{Body: body}}
+	require.NoError(t, rsp.Drain())
+	require.True(t, body.closed)
+	n, _ := body.Read(make([]byte, 1))
+	require.Equal(t, 0, n)
+
+	// A buffered body can still be read
+	rsp = newTestResponse(http.StatusOK, "text/plain", "hello")
+	require.NoError(t, rsp.Buffer())
+	require.NoError(t, rsp.Drain())
+	b, err := rsp.BodyString()
+	require.NoError(t, err)
+	require.Equal(t, "hello", b)
+}
+
+func TestResponse_DecodeUsing_rawTargets(t *testing.T) {
+	rsp := newTestResponse(http.StatusOK, "application/json", `{"result": "ok"}`)
+
+	var v testResult
+	var b []byte
+	var s string
+	require.NoError(t, rsp.Decode(&v, Raw(&b), Raw(&s)))
+	require.Equal(t, "ok", v.Result)
+	require.Equal(t, `{"result": "ok"}`, string(b))
+	require.Equal(t, `{"result": "ok"}`, s)
+
+	// Raw targets are selected by decode hooks as normal
+	var raw []byte
+	require.NoError(t, rsp.Decode(On2xx(Raw(&raw)), On4xx(&s)))
+	require.Equal(t, `{"result": "ok"}`, string(raw))
+
+	// Plain strings are decoded as normal
+	rsp = newTestResponse(http.StatusOK, "application/json", `"abc"`)
+	s = ""
+	require.NoError(t, rsp.Decode(&s))
+	require.Equal(t, "abc", s)
+	s = ""
+	require.NoError(t, rsp.DecodeJSON(&s))
+	require.Equal(t, "abc", s)
+	s, err := DecodeJSON[string](rsp)
+	require.NoError(t, err)
+	require.Equal(t, "abc", s)
+}
+
+func TestResponse_shortBody(t *testing.T) {
+	rsp := newTestResponse(http.StatusOK, "application/json", `{"result": "ok"`)
+	rsp.ContentLength = 20
+
+	var v testResult
+	err := rsp.Decode(&v)
+	require.True(t, errors.Is(err, ErrShortBody))
+	require.EqualError(t, err, "response body shorter than Content-Length: read 15 of 20 bytes")
+
+	// The error is returned by subsequent reads
+	_, err = rsp.BodyBytes()
+	require.True(t, errors.Is(err, ErrShortBody))
+
+	// HEAD responses have a Content-Length but no body
+	rsp = newTestResponse(http.StatusOK, "application/json", "")
+	rsp.ContentLength = 20
+	rsp.Request = &http.Request{Method: http.MethodHead}
+	_, err = rsp.BodyBytes()
+	require.NoError(t, err)
+}
+
+func TestResponse_DecodeError(t *testing.T) {
+	body := "<html>" + strings.Repeat("x", 300) + "</html>"
+	rsp := newTestResponse(http.StatusOK, "application/json", body)
+
+	var v testResult
+	err := rsp.Decode(&v)
+
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr))
+	require.Equal(t, "application/json", decodeErr.ContentType)
+	require.Equal(t, "JSON", decodeErr.Decoder)
+	require.Equal(t, http.StatusOK, decodeErr.Status)
+	require.Equal(t, body[:256], decodeErr.Snippet)
+	require.Equal(t, 1, strings.Count(err.Error(), "failed to decode"))
+
+	var syntaxErr *json.SyntaxError
+	require.True(t, errors.As(err, &syntaxErr))
+}
+
+func TestResponse_BodyMap(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        map[string]interface{}
+	}{
+		{"application/json", `{"name": "Homer", "age": 39}`, map[string]interface{}{"name": "Homer", "age": float64(39)}},
+		{"application/yaml", "name: Homer\nage: 39", map[string]interface{}{"name": "Homer", "age": 39}},
+		{"", `{"name": "Homer"}`, map[string]interface{}{"name": "Homer"}},
+		{"text/plain", `{"name": "Homer"}`, map[string]interface{}{"name": "Homer"}},
+		{"application/json", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			m, err := newTestResponse(http.StatusOK, tt.contentType, tt.body).BodyMap()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, m)
+		})
+	}
+
+	_, err := newTestResponse(http.StatusOK, "application/json", "[1, 2]").BodyMap()
+	require.Error(t, err)
+}
+
+func TestResponse_RawBody(t *testing.T) {
+	body := ioutil.NopCloser(strings.NewReader("hello"))
+	rsp := &Response{Response: &http.Response{Body: body}}
+	require.Equal(t, body, rsp.RawBody())
+
+	// A buffered body can still be read
+	rsp = newTestResponse(http.StatusOK, "text/plain", "hello")
+	require.NoError(t, rsp.Buffer())
+	b, err := ioutil.ReadAll(rsp.RawBody())
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+}
+
+func TestResponse_DecodeCount(t *testing.T) {
+	rsp := newTestResponse(http.StatusOK, "application/json", `{"result": "ok"}`)
+
+	var result testResult
+	var apiErr testError
+	var raw []byte
+	n, err := rsp.DecodeCount(On2xx(&result), On4xx(&apiErr), Raw(&raw))
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	// No hook matches the status
+	rsp = newTestResponse(http.StatusInternalServerError, "application/json", `{"message": "oops"}`)
+	n, err = rsp.DecodeCount(On2xx(&result), On4xx(&apiErr))
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// Nothing is decoded from an empty body
+	rsp = newTestResponse(http.StatusOK, "application/json", "")
+	n, err = rsp.DecodeCount(&result)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
+
+func TestResponse_DecodeAs(t *testing.T) {
+	rsp := newTestResponse(http.StatusOK, "text/html", `{"result": "ok"}`)
+
+	var v testResult
+	require.NoError(t, rsp.DecodeAs("application/json", &v))
+	require.Equal(t, "ok", v.Result)
+
+	rsp = newTestResponse(http.StatusOK, "text/html", "result: ok")
+	v = testResult{}
+	require.NoError(t, rsp.DecodeAs("application/yaml; charset=utf-8", &v))
+	require.Equal(t, "ok", v.Result)
+
+	err := rsp.DecodeAs("application/xml", &v)
+	require.Equal(t, ContentTypeError("application/xml"), err)
+}
+
+func TestResponse_UnmarshalByContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantKey     string
+	}{
+		{
+			name:        "exact",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"result": "ok"}`,
+			wantKey:     "application/json",
+		},
+		{
+			name:        "other format",
+			contentType: "application/yaml",
+			body:        "result: ok",
+			wantKey:     "Application/YAML",
+		},
+		{
+			name:        "suffix",
+			contentType: "application/vnd.api+json",
+			body:        `{"result": "ok"}`,
+			wantKey:     "application/json",
+		},
+		{
+			name:        "wildcard",
+			contentType: "text/yaml",
+			body:        "result: ok",
+			wantKey:     "text/*",
+		},
+		{
+			name:        "no match",
+			contentType: "image/png",
+			body:        "png",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp := newTestResponse(http.StatusOK, tt.contentType, tt.body)
+
+			var jsonResult, yamlResult, textResult testResult
+			targets := map[string]interface{}{
+				"application/json": &jsonResult,
+				"Application/YAML": &yamlResult,
+				"text/*":           &textResult,
+			}
+
+			key, err := rsp.UnmarshalByContentType(targets)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantKey, key)
+
+			if tt.wantKey != "" {
+				require.Equal(t, "ok", targets[tt.wantKey].(*testResult).Result)
+			}
+		})
+	}
+
+	// An exact match for a suffixed type uses the suffix's decoder
+	rsp := newTestResponse(http.StatusBadRequest, "application/problem+json", `{"result": "problem"}`)
+	var problem testResult
+	key, err := rsp.UnmarshalByContentType(map[string]interface{}{"application/problem+json": &problem})
+	require.NoError(t, err)
+	require.Equal(t, "application/problem+json", key)
+	require.Equal(t, "problem", problem.Result)
+
+	// A matching wildcard for an unsupported content type
+	rsp = newTestResponse(http.StatusOK, "image/png", "png")
+	var v testResult
+	_, err = rsp.UnmarshalByContentType(map[string]interface{}{"*/*": &v})
+	require.Equal(t, ContentTypeError("image/png"), err)
+}
+
+func TestResponse_DecodeKeys(t *testing.T) {
+	body := `{"data": {"result": "ok"}, "meta": {"next": "abc"}, "ignored": 1}`
+	rsp := newTestResponse(http.StatusOK, "application/json", body)
+
+	var data testResult
+	var meta struct {
+		Next string `json:"next"`
+	}
+	var missing testResult
+	require.NoError(t, rsp.DecodeKeys(map[string]interface{}{
+		"data":    &data,
+		"meta":    &meta,
+		"missing": &missing,
+	}))
+	require.Equal(t, "ok", data.Result)
+	require.Equal(t, "abc", meta.Next)
+	require.Equal(t, testResult{}, missing)
+
+	// The error names the key that failed
+	rsp = newTestResponse(http.StatusOK, "application/json", `{"data": "not an object"}`)
+	err := rsp.DecodeKeys(map[string]interface{}{"data": &data})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `key "data"`)
+	require.Equal(t, 1, strings.Count(err.Error(), "failed to decode"))
+	var decErr *DecodeError
+	require.True(t, errors.As(err, &decErr))
+}
@@ -0,0 +1,52 @@
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// WithSigV4 signs requests using AWS Signature Version 4. Credentials
+// are retrieved from the provider before each request, so temporary
+// credentials are refreshed as needed. The request body is read to
+// compute the payload hash and is then restored.
+func WithSigV4(credentials aws.CredentialsProvider, region, service string) Option {
+	signer := v4.NewSigner()
+
+	return WithRequestHook(func(req *http.Request) error {
+		creds, err := credentials.Retrieve(req.Context())
+		if err != nil {
+			return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+		}
+
+		payloadHash, err := hashBody(req)
+		if err != nil {
+			return err
+		}
+
+		// Required by S3 and ignored by other services
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+		if err := signer.SignHTTP(req.Context(), creds, req, payloadHash, service, region, time.Now()); err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// hashBody returns the hex-encoded SHA-256 hash of the request body
+func hashBody(req *http.Request) (string, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(body)
+	return hex.EncodeToString(h[:]), nil
+}
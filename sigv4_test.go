@@ -0,0 +1,73 @@
+package patch
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSigV4(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, strings.HasPrefix(
+			r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKID/",
+		))
+		require.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+		require.NotEmpty(t, r.Header.Get("X-Amz-Date"))
+
+		// The body is still sent after being hashed
+		_, err := io.Copy(w, r.Body)
+		require.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	creds := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET"}, nil
+	})
+	c := NewFromBaseClient(srv.Client(), WithSigV4(creds, "eu-west-1", "s3"))
+
+	tests := []struct {
+		name string
+		body interface{}
+		want string
+		hash string
+	}{
+		{
+			name: "no body",
+			hash: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		{
+			name: "encoded body",
+			body: map[string]string{"foo": "bar"},
+			want: `{"foo":"bar"}`,
+			hash: "7a38bf81f383f69433ad6e900d35b3e2385593f76a7b7ab5d4355b8ba41ee24b",
+		},
+		{
+			name: "streamed body",
+			body: ioutil.NopCloser(strings.NewReader(`{"foo":"bar"}`)),
+			want: `{"foo":"bar"}`,
+			hash: "7a38bf81f383f69433ad6e900d35b3e2385593f76a7b7ab5d4355b8ba41ee24b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rsp, err := c.Post(context.Background(), srv.URL, tt.body, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.hash, rsp.Request.Header.Get("X-Amz-Content-Sha256"))
+
+			rspBody, err := rsp.BodyString()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, rspBody)
+		})
+	}
+}
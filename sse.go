@@ -0,0 +1,105 @@
+package patch
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent is an event received from a Server-Sent Events stream
+type SSEEvent struct {
+	// ID is the ID of the most recent event that set one
+	ID string
+
+	// Event is the event type, or empty if not set
+	Event string
+
+	// Data is the event's data. Multiple data lines
+	// are joined with newline characters.
+	Data string
+
+	// Retry is the reconnection time requested by
+	// the server, or zero if the event didn't set one
+	Retry time.Duration
+}
+
+// Events reads a stream of Server-Sent Events (text/event-stream) from
+// the body and calls fn for each event. The body is read incrementally
+// and is not buffered. Reading stops if fn returns an error, which is
+// then returned, or if the request's context is done.
+func (r *Response) Events(fn func(event SSEEvent) error) error {
+	body := r.streamBody()
+	defer func() { _ = body.Close() }()
+
+	reader := bufio.NewReader(body)
+
+	var id string
+	var event SSEEvent
+	var data strings.Builder
+	hasData := false
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			// An incomplete event at the end of the stream is discarded
+			return nil
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		// A blank line dispatches the event
+		if line == "" {
+			if hasData {
+				event.ID = id
+				event.Data = data.String()
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+
+			event = SSEEvent{}
+			data.Reset()
+			hasData = false
+
+			if r.Request != nil {
+				if err := r.Request.Context().Err(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		// Lines starting with a colon are comments
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponse_Events(t *testing.T) {
+	stream := ": comment\n" +
+		"event: greeting\n" +
+		"data: hello\n" +
+		"data:  world\n" +
+		"id: 1\n" +
+		"\n" +
+		"data: no type\r\n" +
+		"retry: 5000\r\n" +
+		"\r\n" +
+		"event: empty\n" +
+		"\n" +
+		"data: incomplete"
+
+	rsp := newTestResponse(http.StatusOK, "text/event-stream", stream)
+
+	var events []SSEEvent
+	require.NoError(t, rsp.Events(func(event SSEEvent) error {
+		events = append(events, event)
+		return nil
+	}))
+
+	require.Equal(t, []SSEEvent{
+		{ID: "1", Event: "greeting", Data: "hello\n world"},
+		{ID: "1", Data: "no type", Retry: 5 * time.Second},
+	}, events)
+
+	// An error from the callback stops reading
+	rsp = newTestResponse(http.StatusOK, "text/event-stream", stream)
+	errStop := errors.New("stop")
+	calls := 0
+	err := rsp.Events(func(event SSEEvent) error {
+		calls++
+		return errStop
+	})
+	require.Equal(t, errStop, err)
+	require.Equal(t, 1, calls)
+}
+
+func TestResponse_Events_cancel(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for {
+			if _, err := w.Write([]byte("data: tick\n\n")); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	c := NewFromBaseClient(srv.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rsp, err := c.Send(&Request{Ctx: ctx, Method: http.MethodGet, URL: srv.URL}).Response()
+	require.NoError(t, err)
+
+	calls := 0
+	err = rsp.Events(func(event SSEEvent) error {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return nil
+	})
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Equal(t, 2, calls)
+}